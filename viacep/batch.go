@@ -0,0 +1,303 @@
+package viacep
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultBatchConcurrency = 8
+
+// BatchOptions configures CepBatch.
+type BatchOptions struct {
+	// Concurrency is the maximum number of CEPs fetched from the API at once. Defaults to 8.
+	Concurrency int
+	// RateLimiter paces the API requests of this batch, on top of any limiter set with
+	// WithRateLimiter. Cache hits do not consume permits.
+	RateLimiter RateLimiter
+	// OnProgress is called each time a distinct CEP is resolved, with the number resolved so far
+	// and the number of distinct valid CEPs in the batch. Calls never overlap.
+	OnProgress func(done, total int)
+}
+
+// BatchResult is the outcome of looking up one input of a batch.
+type BatchResult struct {
+	// Input is the CEP exactly as given to the batch.
+	Input string
+	// Address is the address found, or nil if Err is set.
+	Address *Address
+	// Err is the error of this lookup, such as ErrInvalidCEP or ErrCepNotFound.
+	Err error
+}
+
+// batchItem is a distinct CEP of a batch that could not be answered from the cache directly.
+type batchItem struct {
+	cep   CEP
+	key   string
+	entry cepEntry
+	found bool
+}
+
+// batchWrites collects the entries fetched by a CepBatch call until they are written together.
+type batchWrites struct {
+	mu      sync.Mutex
+	entries map[string]cepEntry
+	stored  bool
+	late    cepStore
+}
+
+// batch gathers the results of a CepBatch call. ceps holds the distinct valid CEPs in order of
+// first appearance and positions the inputs each of them came from.
+type batch struct {
+	mu         sync.Mutex
+	results    []BatchResult
+	ceps       []CEP
+	positions  map[CEP][]int
+	resolved   map[CEP]bool
+	total      int
+	onProgress func(done, total int)
+}
+
+// CepBatch looks up many CEPs and returns one BatchResult per input, in input order.
+//
+// Inputs are normalised with ParseCEP and deduplicated, so each distinct CEP is looked up once.
+// Invalid inputs get an ErrInvalidCEP result without any request. The cache is checked for all
+// CEPs at once, with a single GetMulti call when it is a BatchCache, before the misses are
+// fetched by at most opts.Concurrency workers. The fetched entries are written back once the
+// lookups are done, with one SetMulti call per TTL when the cache is a BatchCache. Per-item
+// failures are reported in the results and do not stop the batch.
+//
+// If ctx is done before the batch completes, CepBatch stops dispatching lookups, waits for the
+// running ones and returns the results gathered so far together with the context error; the
+// inputs that were not looked up carry that error too.
+func (v *ViaCep) CepBatch(ctx context.Context, ceps []string, opts BatchOptions) ([]BatchResult, error) {
+	b := newBatch(ceps, opts.OnProgress)
+	writes := &batchWrites{entries: make(map[string]cepEntry), late: v.storeCep}
+
+	var misses []batchItem
+	for _, item := range v.checkCacheMulti(ctx, b.ceps) {
+		if item.found && v.isFresh(item.entry) {
+			address, err := item.entry.result(item.cep)
+			b.resolve(item.cep, address, err)
+			continue
+		}
+
+		misses = append(misses, item)
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	items := make(chan batchItem)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(misses)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				address, err := v.lookupBatchItem(ctx, item, opts.RateLimiter, writes.add)
+				b.resolve(item.cep, address, err)
+			}
+		}()
+	}
+
+dispatch:
+	for _, item := range misses {
+		select {
+		case items <- item:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+
+	close(items)
+	wg.Wait()
+	v.storeBatch(context.WithoutCancel(ctx), writes)
+
+	if err := ctx.Err(); err != nil {
+		for _, item := range misses {
+			b.resolveIfPending(item.cep, err)
+		}
+
+		return b.results, err
+	}
+
+	return b.results, nil
+}
+
+// checkCache looks cep up in the cache and reports whether the cached entry can be served as is.
+func (v *ViaCep) checkCache(ctx context.Context, cep CEP) (batchItem, bool) {
+	item := batchItem{cep: cep, key: v.cacheKey(cep.String())}
+	found := v.cache.Get(ctx, item.key, &item.entry)
+	item.found = v.cachedCep(found, item.entry)
+	return item, item.found && v.isFresh(item.entry)
+}
+
+// checkCacheMulti looks many CEPs up in the cache at once, in a single round trip when the cache
+// is a BatchCache.
+func (v *ViaCep) checkCacheMulti(ctx context.Context, ceps []CEP) []batchItem {
+	items := make([]batchItem, len(ceps))
+	keys := make([]string, len(ceps))
+	for i, cep := range ceps {
+		items[i] = batchItem{cep: cep, key: v.cacheKey(cep.String())}
+		keys[i] = items[i].key
+	}
+
+	cached := getMulti(ctx, v.cache, keys, func() any { return new(cepEntry) })
+	for i := range items {
+		entry, found := cached[items[i].key]
+		if found {
+			items[i].entry = *entry.(*cepEntry)
+		}
+
+		items[i].found = v.cachedCep(found, items[i].entry)
+	}
+
+	return items
+}
+
+// lookupInput resolves a single input of a batch or stream, from the cache when possible.
+func (v *ViaCep) lookupInput(ctx context.Context, input string, limiter RateLimiter) BatchResult {
+	result := BatchResult{Input: input}
+
+	cep, err := ParseCEP(input)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	item, fresh := v.checkCache(ctx, cep)
+	if fresh {
+		result.Address, result.Err = item.entry.result(cep)
+		return result
+	}
+
+	result.Address, result.Err = v.lookupBatchItem(ctx, item, limiter, v.storeCep)
+	return result
+}
+
+// lookupBatchItem resolves a CEP that could not be served from the cache, waiting for limiter
+// first. The entry fetched, if any, is written with store.
+func (v *ViaCep) lookupBatchItem(ctx context.Context, item batchItem, limiter RateLimiter, store cepStore) (*Address, error) {
+	if limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if item.found {
+		address, _, err := v.serveCached(ctx, item.cep, item.key, item.entry, store)
+		return address, err
+	}
+
+	address, _, err := v.serveFresh(ctx, item.cep, item.key, store)
+	return address, err
+}
+
+// add collects entry to be written by storeBatch. Entries fetched after storeBatch ran, by
+// lookups the batch stopped waiting for, are written right away with late.
+func (w *batchWrites) add(ctx context.Context, key string, entry cepEntry) {
+	w.mu.Lock()
+	if w.stored {
+		w.mu.Unlock()
+		w.late(ctx, key, entry)
+		return
+	}
+
+	w.entries[key] = entry
+	w.mu.Unlock()
+}
+
+// storeBatch writes the entries collected in writes with one setMulti call per TTL.
+func (v *ViaCep) storeBatch(ctx context.Context, writes *batchWrites) {
+	writes.mu.Lock()
+	writes.stored = true
+	entries := writes.entries
+	writes.mu.Unlock()
+
+	byTTL := make(map[time.Duration]map[string]any)
+	for key, entry := range entries {
+		ttl, ok := v.cepEntryTTL(entry)
+		if !ok {
+			continue
+		}
+
+		if byTTL[ttl] == nil {
+			byTTL[ttl] = make(map[string]any)
+		}
+
+		byTTL[ttl][key] = entry
+	}
+
+	for ttl, items := range byTTL {
+		if err := setMulti(ctx, v.cache, items, ttl); err != nil {
+			v.stats.setErrors.Add(uint64(len(items)))
+			continue
+		}
+
+		v.stats.sets.Add(uint64(len(items)))
+	}
+}
+
+func newBatch(ceps []string, onProgress func(done, total int)) *batch {
+	b := &batch{
+		results:    make([]BatchResult, len(ceps)),
+		positions:  make(map[CEP][]int),
+		resolved:   make(map[CEP]bool),
+		onProgress: onProgress,
+	}
+
+	for i, input := range ceps {
+		b.results[i].Input = input
+
+		parsed, err := ParseCEP(input)
+		if err != nil {
+			b.results[i].Err = err
+			continue
+		}
+
+		if _, seen := b.positions[parsed]; !seen {
+			b.ceps = append(b.ceps, parsed)
+		}
+
+		b.positions[parsed] = append(b.positions[parsed], i)
+	}
+
+	b.total = len(b.ceps)
+	return b
+}
+
+// resolve stores the outcome of cep in every input it appeared in; each gets its own copy of address.
+func (b *batch) resolve(cep CEP, address *Address, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.resolveLocked(cep, address, err)
+}
+
+// resolveIfPending fails cep with err unless it was already resolved.
+func (b *batch) resolveIfPending(cep CEP, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.resolved[cep] {
+		b.resolveLocked(cep, nil, err)
+	}
+}
+
+func (b *batch) resolveLocked(cep CEP, address *Address, err error) {
+	for _, i := range b.positions[cep] {
+		b.results[i].Err = err
+		if address != nil {
+			addressCopy := *address
+			b.results[i].Address = &addressCopy
+		}
+	}
+
+	b.resolved[cep] = true
+	if b.onProgress != nil {
+		b.onProgress(len(b.resolved), b.total)
+	}
+}
@@ -0,0 +1,300 @@
+package viacep
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/go-redis/redismock/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// batchServer answers every CEP starting with "9" as unknown and echoes the others. It records
+// the requested paths and the highest number of requests it served at once.
+type batchServer struct {
+	*httptest.Server
+	mu          sync.Mutex
+	paths       []string
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func newBatchServer(t *testing.T) *batchServer {
+	t.Helper()
+
+	s := &batchServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		for {
+			highest := s.maxInFlight.Load()
+			if current <= highest || s.maxInFlight.CompareAndSwap(highest, current) {
+				break
+			}
+		}
+
+		s.mu.Lock()
+		s.paths = append(s.paths, r.URL.Path)
+		s.mu.Unlock()
+
+		cep := strings.Split(r.URL.Path, "/")[2]
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(cep, "9") {
+			_, _ = w.Write([]byte(`{"erro": true}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"cep": "` + cep + `"}`))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *batchServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.paths...)
+}
+
+// countingLimiter is a RateLimiter that never blocks and counts its permits.
+type countingLimiter struct {
+	permits atomic.Int32
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.permits.Add(1)
+	return ctx.Err()
+}
+
+// pipelineCounter is a redis hook counting the commands sent on their own and in pipelines.
+type pipelineCounter struct {
+	commands  atomic.Int32
+	pipelines atomic.Int32
+}
+
+func (h *pipelineCounter) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	h.commands.Add(1)
+	return ctx, nil
+}
+
+func (h *pipelineCounter) AfterProcess(context.Context, redis.Cmder) error {
+	return nil
+}
+
+func (h *pipelineCounter) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	h.pipelines.Add(1)
+	return ctx, nil
+}
+
+func (h *pipelineCounter) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
+
+func TestViaCep_CepBatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("results in input order", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		inputs := []string{"01001-000", "invalid", "99999999", "01001000", "01310100"}
+		results, err := c.CepBatch(ctx, inputs, BatchOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, results, len(inputs))
+
+		for i, result := range results {
+			assert.Equal(t, inputs[i], result.Input)
+		}
+
+		assert.Equal(t, &Address{Cep: "01001000"}, results[0].Address)
+		assert.NoError(t, results[0].Err)
+		assert.Nil(t, results[1].Address)
+		assert.ErrorIs(t, results[1].Err, ErrInvalidCEP)
+		assert.Nil(t, results[2].Address)
+		assert.ErrorIs(t, results[2].Err, ErrCepNotFound)
+		assert.Equal(t, &Address{Cep: "01001000"}, results[3].Address)
+		assert.NotSame(t, results[0].Address, results[3].Address)
+		assert.Equal(t, &Address{Cep: "01310100"}, results[4].Address)
+
+		assert.ElementsMatch(t, []string{"/ws/01001000/json/", "/ws/99999999/json/", "/ws/01310100/json/"}, srv.requests())
+	})
+
+	t.Run("cache hits are not fetched", func(t *testing.T) {
+		srv := newBatchServer(t)
+		limiter := &countingLimiter{}
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		_, err := c.Cep(ctx, "01001000")
+		assert.NoError(t, err)
+		_, err = c.Cep(ctx, "99999999")
+		assert.ErrorIs(t, err, ErrCepNotFound)
+
+		results, err := c.CepBatch(ctx, []string{"01001000", "99999999", "01310100"}, BatchOptions{RateLimiter: limiter})
+		assert.NoError(t, err)
+		assert.NoError(t, results[0].Err)
+		assert.ErrorIs(t, results[1].Err, ErrCepNotFound)
+		assert.NoError(t, results[2].Err)
+
+		assert.Len(t, srv.requests(), 3)
+		assert.Equal(t, int32(1), limiter.permits.Load())
+	})
+
+	t.Run("single round trip to a batch cache", func(t *testing.T) {
+		srv := newBatchServer(t)
+		client, mock := redismock.NewClientMock()
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithCache(NewRedisCache(client)))
+
+		found := cepEntry{Version: CacheSchemaVersion, Address: Address{Cep: "01001-000"}, StoredAt: time.Now()}
+		mock.ExpectMGet(cacheKey("01001000"), cacheKey("99999999")).
+			SetVal([]any{string(encodeGob(t, found)), string(encodeGob(t, cepEntry{Version: CacheSchemaVersion, NotFound: true}))})
+
+		results, err := c.CepBatch(ctx, []string{"01001000", "99999999", "01001-000"}, BatchOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, &Address{Cep: "01001-000"}, results[0].Address)
+		assert.ErrorIs(t, results[1].Err, ErrCepNotFound)
+		assert.Equal(t, &Address{Cep: "01001-000"}, results[2].Address)
+
+		assert.Empty(t, srv.requests())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("fetched entries are written in a single pipeline", func(t *testing.T) {
+		srv := newBatchServer(t)
+		client, mock := redismock.NewClientMock()
+		counter := &pipelineCounter{}
+		client.AddHook(counter)
+		clock := newFakeClock()
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithCache(NewRedisCache(client)), WithClock(clock),
+			WithCacheTTL(time.Hour))
+
+		mock.ExpectMGet(cacheKey("01001000"), cacheKey("01310100")).SetVal([]any{nil, nil})
+		for _, cep := range []string{"01001000", "01310100"} {
+			entry := cepEntry{Version: CacheSchemaVersion, Address: Address{Cep: cep}, StoredAt: clock.Now()}
+			mock.ExpectSet(cacheKey(cep), encodeGob(t, entry), time.Hour).SetVal("OK")
+		}
+
+		results, err := c.CepBatch(ctx, []string{"01001000", "01310100"}, BatchOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, &Address{Cep: "01001000"}, results[0].Address)
+		assert.Equal(t, &Address{Cep: "01310100"}, results[1].Address)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, int32(1), counter.commands.Load(), "only the MGet is sent on its own")
+		assert.Equal(t, int32(1), counter.pipelines.Load())
+		assert.Equal(t, CacheStats{Misses: 2, Sets: 2}, c.Stats())
+	})
+
+	t.Run("tombstones are written under the negative TTL", func(t *testing.T) {
+		srv := newBatchServer(t)
+		client, mock := redismock.NewClientMock()
+		counter := &pipelineCounter{}
+		client.AddHook(counter)
+		clock := newFakeClock()
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithCache(NewRedisCache(client)), WithClock(clock),
+			WithCacheTTL(time.Hour), WithNegativeCacheTTL(time.Minute))
+
+		mock.ExpectMGet(cacheKey("01001000"), cacheKey("99999999"), cacheKey("98888888")).SetVal([]any{nil, nil, nil})
+		mock.MatchExpectationsInOrder(false)
+		entry := cepEntry{Version: CacheSchemaVersion, Address: Address{Cep: "01001000"}, StoredAt: clock.Now()}
+		mock.ExpectSet(cacheKey("01001000"), encodeGob(t, entry), time.Hour).SetVal("OK")
+		tombstone := cepEntry{Version: CacheSchemaVersion, NotFound: true, StoredAt: clock.Now()}
+		mock.ExpectSet(cacheKey("98888888"), encodeGob(t, tombstone), time.Minute).SetVal("OK")
+		mock.ExpectSet(cacheKey("99999999"), encodeGob(t, tombstone), time.Minute).SetVal("OK")
+
+		_, err := c.CepBatch(ctx, []string{"01001000", "99999999", "98888888"}, BatchOptions{})
+		assert.NoError(t, err)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, int32(1), counter.commands.Load())
+		assert.Equal(t, int32(2), counter.pipelines.Load())
+	})
+
+	t.Run("bounded concurrency", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+
+		inputs := make([]string, 40)
+		for i := range inputs {
+			inputs[i] = fmt.Sprintf("010010%02d", i)
+		}
+
+		results, err := c.CepBatch(ctx, inputs, BatchOptions{Concurrency: 3})
+		assert.NoError(t, err)
+		assert.Len(t, results, 40)
+		assert.Len(t, srv.requests(), 40)
+		assert.LessOrEqual(t, srv.maxInFlight.Load(), int32(3))
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+
+		var progress [][2]int
+		_, err := c.CepBatch(ctx, []string{"01001000", "01001-000", "bad", "01310100"}, BatchOptions{
+			OnProgress: func(done, total int) {
+				progress = append(progress, [2]int{done, total})
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, [][2]int{{1, 2}, {2, 2}}, progress)
+	})
+
+	t.Run("cancel returns partial results", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		inputs := []string{"01001000", "01001001", "01001002", "01001003", "01001004"}
+		results, err := c.CepBatch(ctx, inputs, BatchOptions{
+			Concurrency: 1,
+			OnProgress: func(done, _ int) {
+				if done == 1 {
+					cancel()
+				}
+			},
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Len(t, results, len(inputs))
+
+		assert.Equal(t, &Address{Cep: "01001000"}, results[0].Address)
+		assert.NoError(t, results[0].Err)
+		for _, result := range results[1:] {
+			assert.Nil(t, result.Address, result.Input)
+			assert.ErrorIs(t, result.Err, context.Canceled, result.Input)
+		}
+
+		assert.LessOrEqual(t, len(srv.requests()), 2)
+	})
+
+	t.Run("canceled before start", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		results, err := c.CepBatch(ctx, []string{"01001000", "x"}, BatchOptions{})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, results[0].Err, context.Canceled)
+		assert.ErrorIs(t, results[1].Err, ErrInvalidCEP)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		results, err := New(WithNoCache()).CepBatch(ctx, nil, BatchOptions{})
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+	})
+}
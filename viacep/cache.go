@@ -1,11 +1,13 @@
 package viacep
 
 import (
-	"bytes"
+	"container/list"
 	"context"
 	"crypto/sha256"
-	"encoding/gob"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -14,7 +16,23 @@ import (
 )
 
 const cacheTTL = 3600 * time.Second
-const cachePrefix = "viacep:"
+const negativeCacheTTL = 300 * time.Second
+const revalidateTimeout = 10 * time.Second
+const defaultCacheNamespace = "viacep"
+
+// CacheSchemaVersion is the version of the layout of the values ViaCep stores in its cache. It is
+// part of every cache key and every cached value, and is increased whenever Address or the cached
+// values change, so that entries written by other versions are refetched instead of being decoded
+// partially. Entries written before versioning was introduced have version 0.
+const CacheSchemaVersion = 1
+
+// purgeScanCount is the number of keys requested per SCAN iteration by PurgeVersion.
+const purgeScanCount = 1000
+
+const (
+	defaultMaxEntries      = 100_000
+	defaultJanitorInterval = time.Minute
+)
 
 type Cache interface {
 	// Get retrieves an item from the cache by its key.
@@ -74,111 +92,567 @@ type Cache interface {
 	Delete(ctx context.Context, key string) error
 }
 
-type memoryCache struct {
-	mu   sync.RWMutex
-	data map[string][]byte
+// BatchCache is an optional extension of Cache for caches that can read and write many keys in a
+// single operation. ViaCep detects it and uses it to check the cache for a whole batch at once.
+type BatchCache interface {
+	Cache
+
+	// GetMulti retrieves many items from the cache at once.
+	//
+	// Parameters:
+	//   - ctx: The context for managing cancellation, timeouts, and deadlines.
+	//   - keys: The keys of the cache entries to retrieve.
+	//   - destFactory: Returns a new pointer to decode one cached value into. It is called once
+	//                  for each key found.
+	//
+	// Returns:
+	//   - A map from each key found to the pointer returned by destFactory, holding its value.
+	//     Keys that are missing or whose value cannot be decoded are left out.
+	//
+	// Example:
+	//   found := cache.GetMulti(ctx, []string{"user:1", "user:2"}, func() any { return new(User) })
+	//   for key, value := range found {
+	//       fmt.Println(key, value.(*User).Name)
+	//   }
+	GetMulti(ctx context.Context, keys []string, destFactory func() any) map[string]any
+
+	// SetMulti stores many items in the cache with the same time-to-live (TTL).
+	//
+	// Parameters:
+	//   - ctx: The context for managing cancellation, timeouts, and deadlines.
+	//   - items: The values to store, by key.
+	//   - ttl: The time-to-live (TTL) duration for the cache entries. If ttl is zero, the entries will not expire.
+	//
+	// Returns:
+	//   - An error if a value cannot be encoded, in which case nothing is stored, or if the cache
+	//     operation fails.
+	//
+	// Example:
+	//   err := cache.SetMulti(ctx, map[string]any{"user:1": alice, "user:2": bob}, 10*time.Minute)
+	//   if err != nil {
+	//       fmt.Println("Error setting cache:", err)
+	//   }
+	SetMulti(ctx context.Context, items map[string]any, ttl time.Duration) error
+}
+
+// MemoryCache is an in-process Cache bounded by entry count and/or byte size. When a limit
+// is exceeded the least recently used entries are evicted. Expired entries are dropped on
+// access and periodically by a single background janitor, which is stopped by Close.
+type MemoryCache struct {
+	mu              sync.Mutex
+	items           map[string]*list.Element
+	lru             *list.List
+	size            int64
+	maxEntries      int
+	maxBytes        int64
+	janitorInterval time.Duration
+	clock           Clock
+	codec           Codec
+	stop            chan struct{}
+	closeOnce       sync.Once
+	counters        cacheCounters
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
 }
 
+// MemoryCacheOption configures a MemoryCache created with NewMemoryCache.
+type MemoryCacheOption func(*MemoryCache)
+
 type RedisCache struct {
-	client *redis.Client
+	client   *redis.Client
+	codec    Codec
+	counters cacheCounters
 }
 
-func cacheKey(value ...string) string {
+// RedisCacheOption configures a RedisCache created with NewRedisCache.
+type RedisCacheOption func(*RedisCache)
+
+// noopCache never stores anything; it backs WithNoCache.
+type noopCache struct{}
+
+// cacheKey returns the key of values in the default namespace at the current schema version.
+func cacheKey(values ...string) string {
+	return versionedCacheKey(defaultCacheNamespace, CacheSchemaVersion, values...)
+}
+
+// versionedCacheKey returns "<namespace>:v<version>:<sha256 of values>". Version 0 is the layout
+// used before keys were versioned, "<namespace>:<sha256 of values>".
+func versionedCacheKey(namespace string, version int, values ...string) string {
 	hash := sha256.New()
-	hash.Write([]byte(strings.Join(value, ",")))
-	return fmt.Sprintf("%s%x", cachePrefix, hash.Sum(nil))
+	hash.Write([]byte(strings.Join(values, ",")))
+
+	if version == 0 {
+		return fmt.Sprintf("%s:%x", namespace, hash.Sum(nil))
+	}
+
+	return fmt.Sprintf("%s:v%d:%x", namespace, version, hash.Sum(nil))
 }
 
-func newMemoryCache() *memoryCache {
-	return &memoryCache{
-		data: make(map[string][]byte),
+// versionedCacheKeyPattern returns a SCAN pattern matching the keys of versionedCacheKey for
+// namespace and version, and no others.
+func versionedCacheKeyPattern(namespace string, version int) string {
+	namespace = globEscaper.Replace(namespace)
+	hash := strings.Repeat("?", sha256.Size*2)
+
+	if version == 0 {
+		return fmt.Sprintf("%s:%s", namespace, hash)
 	}
+
+	return fmt.Sprintf("%s:v%d:%s", namespace, version, hash)
 }
 
-func NewRedisCache(client *redis.Client) *RedisCache {
-	return &RedisCache{
-		client: client,
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// WithMaxEntries limits the number of entries held by a MemoryCache. A value <= 0 removes the limit.
+func WithMaxEntries(maxEntries int) MemoryCacheOption {
+	return func(c *MemoryCache) {
+		c.maxEntries = maxEntries
 	}
 }
 
-func (c *memoryCache) Get(_ context.Context, key string, dest any) bool {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+// WithMaxBytes limits the total size of keys and encoded values held by a MemoryCache.
+// A value <= 0 removes the limit.
+func WithMaxBytes(maxBytes int64) MemoryCacheOption {
+	return func(c *MemoryCache) {
+		c.maxBytes = maxBytes
+	}
+}
+
+// WithJanitorInterval sets how often expired entries are swept. A value <= 0 disables the
+// janitor, leaving expired entries to be dropped when they are accessed or evicted.
+func WithJanitorInterval(interval time.Duration) MemoryCacheOption {
+	return func(c *MemoryCache) {
+		c.janitorInterval = interval
+	}
+}
+
+// WithMemoryCacheCodec sets the codec used to serialize values. Defaults to GobCodec.
+func WithMemoryCacheCodec(codec Codec) MemoryCacheOption {
+	return func(c *MemoryCache) {
+		c.codec = codec
+	}
+}
+
+// WithMemoryCacheClock sets the clock used to compute and check expiry times.
+func WithMemoryCacheClock(clock Clock) MemoryCacheOption {
+	return func(c *MemoryCache) {
+		c.clock = clock
+	}
+}
+
+// NewMemoryCache creates a MemoryCache. By default it holds up to 100000 entries, has no byte
+// limit and sweeps expired entries every minute.
+func NewMemoryCache(opts ...MemoryCacheOption) *MemoryCache {
+	c := &MemoryCache{
+		items:           make(map[string]*list.Element),
+		lru:             list.New(),
+		maxEntries:      defaultMaxEntries,
+		janitorInterval: defaultJanitorInterval,
+		clock:           systemClock{},
+		codec:           GobCodec{},
+		stop:            make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
 
-	serialized, exists := c.data[key]
+	if c.janitorInterval > 0 {
+		go c.janitor()
+	}
+
+	return c
+}
+
+// WithRedisCacheCodec sets the codec used to serialize values. Defaults to GobCodec.
+func WithRedisCacheCodec(codec Codec) RedisCacheOption {
+	return func(r *RedisCache) {
+		r.codec = codec
+	}
+}
+
+func NewRedisCache(client *redis.Client, opts ...RedisCacheOption) *RedisCache {
+	r := &RedisCache{
+		client: client,
+		codec:  GobCodec{},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func (c *MemoryCache) Get(_ context.Context, key string, dest any) bool {
+	serialized, exists := c.get(key)
 	if !exists {
-		return false
+		return c.counters.read(false)
 	}
 
-	buffer := bytes.NewBuffer(serialized)
-	decoder := gob.NewDecoder(buffer)
-	if err := decoder.Decode(dest); err != nil {
+	if err := c.codec.Unmarshal(serialized, dest); err != nil {
+		c.counters.decodeFailure()
 		return false
 	}
 
-	return true
+	return c.counters.read(true)
 }
 
-func (c *memoryCache) Set(_ context.Context, key string, value any, ttl time.Duration) error {
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	if err := encoder.Encode(value); err != nil {
+func (c *MemoryCache) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	serialized, err := c.codec.Marshal(value)
+	if err != nil {
+		c.counters.setErrors.Add(1)
 		return fmt.Errorf("failed to encode value of type %T: %w", value, err)
 	}
 
+	c.set(key, serialized, ttl)
+	c.counters.sets.Add(1)
+	return nil
+}
+
+func (c *MemoryCache) GetMulti(_ context.Context, keys []string, destFactory func() any) map[string]any {
+	serialized := make(map[string][]byte, len(keys))
+
+	c.mu.Lock()
+	now := c.clock.Now()
+	for _, key := range keys {
+		if value, exists := c.getLocked(key, now); exists {
+			serialized[key] = value
+		}
+	}
+	c.mu.Unlock()
+
+	c.counters.misses.Add(uint64(len(keys) - len(serialized)))
+
+	found := make(map[string]any, len(serialized))
+	for key, value := range serialized {
+		dest := destFactory()
+		if err := c.codec.Unmarshal(value, dest); err != nil {
+			c.counters.decodeFailure()
+			continue
+		}
+
+		c.counters.hits.Add(1)
+		found[key] = dest
+	}
+
+	return found
+}
+
+func (c *MemoryCache) SetMulti(_ context.Context, items map[string]any, ttl time.Duration) error {
+	serialized, err := marshalMulti(c.codec, items)
+	if err != nil {
+		c.counters.setErrors.Add(uint64(len(items)))
+		return err
+	}
+
+	expiresAt := c.expiresAt(ttl)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.data[key] = buffer.Bytes()
+	for key, value := range serialized {
+		c.setLocked(key, value, expiresAt)
+	}
+
+	c.counters.sets.Add(uint64(len(serialized)))
+	return nil
+}
 
-	if ttl > 0 {
-		go func() {
-			time.Sleep(ttl)
-			_ = c.Delete(context.TODO(), key)
-		}()
+func (c *MemoryCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, exists := c.items[key]; exists {
+		c.remove(elem)
 	}
 
 	return nil
 }
 
-func (c *memoryCache) Delete(_ context.Context, key string) error {
+// Len returns the number of entries currently held, including expired entries not yet swept.
+func (c *MemoryCache) Len() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	delete(c.data, key)
+	return c.lru.Len()
+}
+
+// Stats returns a snapshot of the activity of the cache. Evictions counts the entries dropped
+// because they expired or to stay within the configured limits.
+func (c *MemoryCache) Stats() CacheStats {
+	stats := c.counters.snapshot()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats.Entries = int64(c.lru.Len())
+	stats.Bytes = c.size
+	return stats
+}
+
+// Close stops the background janitor. The cache remains usable afterwards.
+func (c *MemoryCache) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+
 	return nil
 }
 
+func (c *MemoryCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.getLocked(key, c.clock.Now())
+}
+
+func (c *MemoryCache) getLocked(key string, now time.Time) ([]byte, bool) {
+	elem, exists := c.items[key]
+	if !exists {
+		return nil, false
+	}
+
+	entry := elem.Value.(*memoryEntry)
+	if c.expired(entry, now) {
+		c.evict(elem)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *MemoryCache) set(key string, value []byte, ttl time.Duration) {
+	expiresAt := c.expiresAt(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setLocked(key, value, expiresAt)
+}
+
+func (c *MemoryCache) expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+
+	return c.clock.Now().Add(ttl)
+}
+
+func (c *MemoryCache) setLocked(key string, value []byte, expiresAt time.Time) {
+	if elem, exists := c.items[key]; exists {
+		entry := elem.Value.(*memoryEntry)
+		c.size += int64(len(value) - len(entry.value))
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.lru.MoveToFront(elem)
+	} else {
+		entry := &memoryEntry{key: key, value: value, expiresAt: expiresAt}
+		c.items[key] = c.lru.PushFront(entry)
+		c.size += entrySize(entry)
+	}
+
+	for c.overLimit() {
+		c.evict(c.lru.Back())
+	}
+}
+
+func (c *MemoryCache) overLimit() bool {
+	if c.lru.Len() == 0 {
+		return false
+	}
+
+	return (c.maxEntries > 0 && c.lru.Len() > c.maxEntries) || (c.maxBytes > 0 && c.size > c.maxBytes)
+}
+
+func (c *MemoryCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*memoryEntry)
+	delete(c.items, entry.key)
+	c.size -= entrySize(entry)
+}
+
+// evict removes an entry that expired or no longer fits, counting it as an eviction.
+func (c *MemoryCache) evict(elem *list.Element) {
+	c.remove(elem)
+	c.counters.evictions.Add(1)
+}
+
+func (c *MemoryCache) expired(entry *memoryEntry, now time.Time) bool {
+	return !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt)
+}
+
+func (c *MemoryCache) deleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	for elem := c.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if c.expired(elem.Value.(*memoryEntry), now) {
+			c.evict(elem)
+		}
+		elem = prev
+	}
+}
+
+func (c *MemoryCache) janitor() {
+	ticker := time.NewTicker(c.janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.deleteExpired()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func entrySize(entry *memoryEntry) int64 {
+	return int64(len(entry.key) + len(entry.value))
+}
+
 func (r *RedisCache) Get(ctx context.Context, key string, dest any) bool {
-	val, err := r.client.Get(ctx, key).Result()
+	val, err := r.client.Get(ctx, key).Bytes()
 	if err != nil {
-		return false
+		return r.counters.read(false)
 	}
 
-	buffer := bytes.NewBuffer([]byte(val))
-	decoder := gob.NewDecoder(buffer)
-	if err := decoder.Decode(dest); err != nil {
+	if err := r.codec.Unmarshal(val, dest); err != nil {
+		r.counters.decodeFailure()
 		return false
 	}
 
-	return true
+	return r.counters.read(true)
 }
 
 func (r *RedisCache) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	if err := encoder.Encode(value); err != nil {
+	serialized, err := r.codec.Marshal(value)
+	if err != nil {
+		r.counters.setErrors.Add(1)
 		return fmt.Errorf("failed to encode value of type %T: %w", value, err)
 	}
 
-	err := r.client.Set(ctx, key, buffer.Bytes(), ttl).Err()
+	err = r.client.Set(ctx, key, serialized, ttl).Err()
 	if err != nil {
+		r.counters.setErrors.Add(1)
 		return fmt.Errorf("failed to set value in cache: %w", err)
 	}
 
+	r.counters.sets.Add(1)
+	return nil
+}
+
+func (r *RedisCache) GetMulti(ctx context.Context, keys []string, destFactory func() any) map[string]any {
+	found := make(map[string]any)
+	if len(keys) == 0 {
+		return found
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		r.counters.misses.Add(uint64(len(keys)))
+		return found
+	}
+
+	for i, value := range values {
+		serialized, ok := value.(string)
+		if !ok {
+			r.counters.misses.Add(1)
+			continue
+		}
+
+		dest := destFactory()
+		if err := r.codec.Unmarshal([]byte(serialized), dest); err != nil {
+			r.counters.decodeFailure()
+			continue
+		}
+
+		r.counters.hits.Add(1)
+		found[keys[i]] = dest
+	}
+
+	return found
+}
+
+func (r *RedisCache) SetMulti(ctx context.Context, items map[string]any, ttl time.Duration) error {
+	serialized, err := marshalMulti(r.codec, items)
+	if err != nil {
+		r.counters.setErrors.Add(uint64(len(items)))
+		return err
+	}
+
+	if len(serialized) == 0 {
+		return nil
+	}
+
+	cmds, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range slices.Sorted(maps.Keys(serialized)) {
+			pipe.Set(ctx, key, serialized[key], ttl)
+		}
+
+		return nil
+	})
+
+	for _, cmd := range cmds {
+		r.counters.write(cmd.Err())
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to set values in cache: %w", err)
+	}
+
 	return nil
 }
 
+// Stats returns a snapshot of the activity of the cache through this instance. Evictions,
+// Entries and Bytes are managed by the Redis server and are not reported.
+func (r *RedisCache) Stats() CacheStats {
+	return r.counters.snapshot()
+}
+
+// PurgeVersion deletes the entries that ViaCep clients using namespace stored at the given schema
+// version, e.g. those left behind by an older release after an upgrade. Keys are found with SCAN
+// so the server is never blocked, and deleted batch by batch. It returns the number of keys
+// deleted, which is accurate also when an error is returned.
+//
+// Example:
+//
+//	deleted, err := cache.PurgeVersion(ctx, "viacep", viacep.CacheSchemaVersion-1)
+func (r *RedisCache) PurgeVersion(ctx context.Context, namespace string, version int) (int64, error) {
+	pattern := versionedCacheKeyPattern(namespace, version)
+
+	var deleted int64
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, pattern, purgeScanCount).Result()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to scan cache keys: %w", err)
+		}
+
+		if len(keys) > 0 {
+			n, delErr := r.client.Del(ctx, keys...).Result()
+			if delErr != nil {
+				return deleted, fmt.Errorf("failed to delete keys from cache: %w", delErr)
+			}
+
+			deleted += n
+		}
+
+		if next == 0 {
+			return deleted, nil
+		}
+
+		cursor = next
+	}
+}
+
 func (r *RedisCache) Delete(ctx context.Context, key string) error {
 	err := r.client.Del(ctx, key).Err()
 	if err != nil {
@@ -187,3 +661,65 @@ func (r *RedisCache) Delete(ctx context.Context, key string) error {
 
 	return nil
 }
+
+// marshalMulti encodes the values of items, failing on the first value that cannot be encoded.
+func marshalMulti(codec Codec, items map[string]any) (map[string][]byte, error) {
+	serialized := make(map[string][]byte, len(items))
+	for key, value := range items {
+		encoded, err := codec.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode value of type %T for key %s: %w", value, key, err)
+		}
+
+		serialized[key] = encoded
+	}
+
+	return serialized, nil
+}
+
+// getMulti reads keys with a single GetMulti call when cache is a BatchCache, and with one Get
+// per key otherwise.
+func getMulti(ctx context.Context, cache Cache, keys []string, destFactory func() any) map[string]any {
+	if batchCache, ok := cache.(BatchCache); ok {
+		return batchCache.GetMulti(ctx, keys, destFactory)
+	}
+
+	found := make(map[string]any)
+	for _, key := range keys {
+		dest := destFactory()
+		if cache.Get(ctx, key, dest) {
+			found[key] = dest
+		}
+	}
+
+	return found
+}
+
+// setMulti writes items with a single SetMulti call when cache is a BatchCache, and with one Set
+// per key otherwise.
+func setMulti(ctx context.Context, cache Cache, items map[string]any, ttl time.Duration) error {
+	if batchCache, ok := cache.(BatchCache); ok {
+		return batchCache.SetMulti(ctx, items, ttl)
+	}
+
+	var errs []error
+	for key, value := range items {
+		if err := cache.Set(ctx, key, value, ttl); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (noopCache) Get(context.Context, string, any) bool {
+	return false
+}
+
+func (noopCache) Set(context.Context, string, any, time.Duration) error {
+	return nil
+}
+
+func (noopCache) Delete(context.Context, string) error {
+	return nil
+}
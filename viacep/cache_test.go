@@ -5,6 +5,10 @@ import (
 	"context"
 	"encoding/gob"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -12,31 +16,65 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func encodeGob(t *testing.T, value any) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(value)
+	assert.NoError(t, err)
+
+	return buffer.Bytes()
+}
+
 func TestViaCep_MemoryCache_cacheKey(t *testing.T) {
 	t.Run("multiple values", func(t *testing.T) {
-		expected := "viacep:93046c72a31da34f3f01241343d00bddc8edc3b386ebaef62f3b5083ec6257d9"
+		expected := "viacep:v1:93046c72a31da34f3f01241343d00bddc8edc3b386ebaef62f3b5083ec6257d9"
 		result := cacheKey("part1", "part2", "part3")
 		assert.Equal(t, expected, result)
 	})
 
 	t.Run("single value", func(t *testing.T) {
-		expected := "viacep:947f187506f7629c81c81879a2cb2256455038e4ac770091d897fa0a8b945e3b"
+		expected := "viacep:v1:947f187506f7629c81c81879a2cb2256455038e4ac770091d897fa0a8b945e3b"
 		result := cacheKey("single")
 		assert.Equal(t, expected, result)
 	})
 
 	t.Run("empty input", func(t *testing.T) {
-		expected := "viacep:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		expected := "viacep:v1:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
 		result := cacheKey()
 		assert.Equal(t, expected, result)
 	})
 }
 
+func TestViaCep_Cache_versionedCacheKey(t *testing.T) {
+	t.Run("namespace and version", func(t *testing.T) {
+		expected := "tenant-a:v7:947f187506f7629c81c81879a2cb2256455038e4ac770091d897fa0a8b945e3b"
+		assert.Equal(t, expected, versionedCacheKey("tenant-a", 7, "single"))
+	})
+
+	t.Run("legacy layout for version 0", func(t *testing.T) {
+		expected := "viacep:93046c72a31da34f3f01241343d00bddc8edc3b386ebaef62f3b5083ec6257d9"
+		assert.Equal(t, expected, versionedCacheKey("viacep", 0, "part1", "part2", "part3"))
+	})
+}
+
+func TestViaCep_Cache_versionedCacheKeyPattern(t *testing.T) {
+	hash := strings.Repeat("?", 64)
+
+	t.Run("matches the keys of a version", func(t *testing.T) {
+		assert.Equal(t, "viacep:v1:"+hash, versionedCacheKeyPattern("viacep", 1))
+		assert.Equal(t, "viacep:"+hash, versionedCacheKeyPattern("viacep", 0))
+	})
+
+	t.Run("escapes the namespace", func(t *testing.T) {
+		assert.Equal(t, `a\*b\?\[c\]\\:v2:`+hash, versionedCacheKeyPattern(`a*b?[c]\`, 2))
+	})
+}
+
 func TestViaCep_MemoryCache_Get(t *testing.T) {
-	cache := newMemoryCache()
-	cache.mu.Lock()
-	cache.data["user:1"] = []byte("invalid data")
-	cache.mu.Unlock()
+	cache := NewMemoryCache()
+	defer cache.Close()
+	cache.set("user:1", []byte("invalid data"), 0)
 
 	type dummy struct {
 		ID   int
@@ -66,9 +104,7 @@ func TestViaCep_MemoryCache_Get(t *testing.T) {
 	})
 
 	t.Run("deserialization error", func(t *testing.T) {
-		cache.mu.Lock()
-		cache.data["user:invalid"] = []byte("invalid data")
-		cache.mu.Unlock()
+		cache.set("user:invalid", []byte("invalid data"), 0)
 
 		var dest dummy
 
@@ -80,7 +116,8 @@ func TestViaCep_MemoryCache_Get(t *testing.T) {
 }
 
 func TestViaCep_MemoryCache_Set(t *testing.T) {
-	cache := newMemoryCache()
+	cache := NewMemoryCache()
+	defer cache.Close()
 
 	type dummy struct {
 		ID   int
@@ -133,10 +170,120 @@ func TestViaCep_MemoryCache_Set(t *testing.T) {
 		assert.False(t, found)
 		assert.Equal(t, dummy{}, dest2)
 	})
+
+	t.Run("overwrite resets TTL", func(t *testing.T) {
+		err := cache.Set(context.Background(), "user:2", model, 10*time.Millisecond)
+		assert.NoError(t, err)
+
+		err = cache.Set(context.Background(), "user:2", model, 0)
+		assert.NoError(t, err)
+
+		time.Sleep(40 * time.Millisecond)
+
+		var dest dummy
+		found := cache.Get(context.Background(), "user:2", &dest)
+		assert.True(t, found)
+		assert.Equal(t, model, dest)
+	})
+}
+
+func TestViaCep_MemoryCache_Eviction(t *testing.T) {
+	t.Run("least recently used entry is evicted", func(t *testing.T) {
+		cache := NewMemoryCache(WithMaxEntries(2))
+		defer cache.Close()
+
+		ctx := context.Background()
+		assert.NoError(t, cache.Set(ctx, "a", 1, 0))
+		assert.NoError(t, cache.Set(ctx, "b", 2, 0))
+
+		var dest int
+		assert.True(t, cache.Get(ctx, "a", &dest))
+
+		assert.NoError(t, cache.Set(ctx, "c", 3, 0))
+		assert.Equal(t, 2, cache.Len())
+		assert.True(t, cache.Get(ctx, "a", &dest))
+		assert.False(t, cache.Get(ctx, "b", &dest))
+		assert.True(t, cache.Get(ctx, "c", &dest))
+	})
+
+	t.Run("byte limit", func(t *testing.T) {
+		cache := NewMemoryCache(WithMaxEntries(0), WithMaxBytes(10))
+		defer cache.Close()
+
+		cache.set("a", []byte("1234"), 0)
+		cache.set("b", []byte("1234"), 0)
+		assert.Equal(t, int64(10), cache.size)
+
+		cache.set("c", []byte("12"), 0)
+		assert.Equal(t, int64(8), cache.size)
+		assert.Equal(t, 2, cache.Len())
+
+		_, found := cache.get("a")
+		assert.False(t, found)
+
+		cache.set("b", []byte("12345678"), 0)
+		assert.Equal(t, int64(9), cache.size)
+		assert.Equal(t, 1, cache.Len())
+
+		cache.set("d", []byte("this value is larger than the limit"), 0)
+		assert.Equal(t, int64(0), cache.size)
+		assert.Equal(t, 0, cache.Len())
+	})
+
+	t.Run("unbounded", func(t *testing.T) {
+		cache := NewMemoryCache(WithMaxEntries(0), WithJanitorInterval(0))
+		defer cache.Close()
+
+		for i := range 1000 {
+			cache.set(fmt.Sprint(i), []byte("x"), 0)
+		}
+
+		assert.Equal(t, 1000, cache.Len())
+	})
+}
+
+func TestViaCep_MemoryCache_Janitor(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewMemoryCache(WithMemoryCacheClock(clock), WithJanitorInterval(time.Millisecond))
+	defer cache.Close()
+
+	cache.set("short", []byte("x"), time.Second)
+	cache.set("long", []byte("x"), time.Hour)
+	cache.set("forever", []byte("x"), 0)
+	assert.Equal(t, 3, cache.Len())
+
+	clock.Advance(time.Minute)
+
+	assert.Eventually(t, func() bool {
+		return cache.Len() == 2
+	}, time.Second, time.Millisecond)
+
+	_, found := cache.get("long")
+	assert.True(t, found)
+
+	clock.Advance(time.Hour)
+	_, found = cache.get("long")
+	assert.False(t, found)
+
+	_, found = cache.get("forever")
+	assert.True(t, found)
+}
+
+func TestViaCep_MemoryCache_Close(t *testing.T) {
+	cache := NewMemoryCache(WithJanitorInterval(time.Millisecond))
+	assert.NoError(t, cache.Close())
+	assert.NoError(t, cache.Close())
+
+	assert.NoError(t, cache.Set(context.Background(), "user:1", 1, 0))
+
+	var dest int
+	assert.True(t, cache.Get(context.Background(), "user:1", &dest))
+	assert.Equal(t, 1, dest)
 }
 
 func TestViaCep_MemoryCache_Delete(t *testing.T) {
-	cache := newMemoryCache()
+	cache := NewMemoryCache()
+	defer cache.Close()
 
 	type dummy struct {
 		ID   int
@@ -159,6 +306,86 @@ func TestViaCep_MemoryCache_Delete(t *testing.T) {
 	})
 }
 
+func TestViaCep_MemoryCache_GetMulti(t *testing.T) {
+	cache := NewMemoryCache()
+	defer cache.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, cache.Set(ctx, "user:1", "alice", 0))
+	assert.NoError(t, cache.Set(ctx, "user:2", "bob", 0))
+	cache.set("user:invalid", []byte("invalid data"), 0)
+
+	t.Run("found keys only", func(t *testing.T) {
+		found := cache.GetMulti(ctx, []string{"user:1", "user:2", "user:3", "user:invalid"}, func() any { return new(string) })
+		assert.Len(t, found, 2)
+		assert.Equal(t, "alice", *found["user:1"].(*string))
+		assert.Equal(t, "bob", *found["user:2"].(*string))
+	})
+
+	t.Run("expired keys", func(t *testing.T) {
+		clock := newFakeClock()
+		cache := NewMemoryCache(WithMemoryCacheClock(clock), WithJanitorInterval(0))
+		assert.NoError(t, cache.Set(ctx, "short", "value", time.Minute))
+		assert.NoError(t, cache.Set(ctx, "long", "value", time.Hour))
+
+		clock.Advance(2 * time.Minute)
+		found := cache.GetMulti(ctx, []string{"short", "long"}, func() any { return new(string) })
+		assert.Len(t, found, 1)
+		assert.Contains(t, found, "long")
+		assert.Equal(t, 1, cache.Len())
+	})
+
+	t.Run("no keys", func(t *testing.T) {
+		assert.Empty(t, cache.GetMulti(ctx, nil, func() any { return new(string) }))
+	})
+}
+
+func TestViaCep_MemoryCache_SetMulti(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("set and retrieve successfully", func(t *testing.T) {
+		cache := NewMemoryCache()
+		defer cache.Close()
+
+		err := cache.SetMulti(ctx, map[string]any{"user:1": "alice", "user:2": "bob"}, 0)
+		assert.NoError(t, err)
+
+		var dest string
+		assert.True(t, cache.Get(ctx, "user:1", &dest))
+		assert.Equal(t, "alice", dest)
+		assert.True(t, cache.Get(ctx, "user:2", &dest))
+		assert.Equal(t, "bob", dest)
+	})
+
+	t.Run("TTL expiry", func(t *testing.T) {
+		clock := newFakeClock()
+		cache := NewMemoryCache(WithMemoryCacheClock(clock), WithJanitorInterval(0))
+
+		err := cache.SetMulti(ctx, map[string]any{"user:1": "alice", "user:2": "bob"}, time.Minute)
+		assert.NoError(t, err)
+
+		clock.Advance(time.Minute)
+		assert.Empty(t, cache.GetMulti(ctx, []string{"user:1", "user:2"}, func() any { return new(string) }))
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		cache := NewMemoryCache(WithMaxEntries(2), WithJanitorInterval(0))
+
+		err := cache.SetMulti(ctx, map[string]any{"a": 1, "b": 2, "c": 3}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, cache.Len())
+	})
+
+	t.Run("serialization error stores nothing", func(t *testing.T) {
+		cache := NewMemoryCache()
+		defer cache.Close()
+
+		err := cache.SetMulti(ctx, map[string]any{"ok": "value", "invalid": make(chan int)}, 0)
+		assert.EqualError(t, err, "failed to encode value of type chan int for key invalid: gob NewTypeObject can't handle type: chan int")
+		assert.Equal(t, 0, cache.Len())
+	})
+}
+
 func TestViaCep_RedisCache_Get(t *testing.T) {
 	type dummy struct {
 		ID   int
@@ -338,6 +565,90 @@ func TestViaCep_RedisCache_Set(t *testing.T) {
 	})
 }
 
+func TestViaCep_RedisCache_GetMulti(t *testing.T) {
+	ctx := context.Background()
+	newDest := func() any { return new(string) }
+
+	t.Run("retrieve values with success", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		cache := NewRedisCache(client)
+
+		mock.ExpectMGet("user:1", "user:2", "user:3", "user:invalid").
+			SetVal([]any{string(encodeGob(t, "alice")), nil, string(encodeGob(t, "carol")), "invalid data"})
+
+		found := cache.GetMulti(ctx, []string{"user:1", "user:2", "user:3", "user:invalid"}, newDest)
+		assert.Len(t, found, 2)
+		assert.Equal(t, "alice", *found["user:1"].(*string))
+		assert.Equal(t, "carol", *found["user:3"].(*string))
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error get values", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		cache := NewRedisCache(client)
+
+		mock.ExpectMGet("user:1").SetErr(errors.New("error"))
+
+		assert.Empty(t, cache.GetMulti(ctx, []string{"user:1"}, newDest))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no keys", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		cache := NewRedisCache(client)
+
+		assert.Empty(t, cache.GetMulti(ctx, nil, newDest))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestViaCep_RedisCache_SetMulti(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("pipelined set with TTL", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		cache := NewRedisCache(client)
+
+		ttl := 10 * time.Minute
+		mock.ExpectSet("user:1", encodeGob(t, "alice"), ttl).SetVal("OK")
+		mock.ExpectSet("user:2", encodeGob(t, "bob"), ttl).SetVal("OK")
+
+		err := cache.SetMulti(ctx, map[string]any{"user:2": "bob", "user:1": "alice"}, ttl)
+		assert.NoError(t, err)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error set values", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		cache := NewRedisCache(client)
+
+		mock.ExpectSet("user:1", encodeGob(t, "alice"), 0).SetErr(errors.New("error"))
+
+		err := cache.SetMulti(ctx, map[string]any{"user:1": "alice"}, 0)
+		assert.EqualError(t, err, "failed to set values in cache: error")
+	})
+
+	t.Run("serialization error", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		cache := NewRedisCache(client)
+
+		err := cache.SetMulti(ctx, map[string]any{"invalid": func() {}}, 0)
+		assert.EqualError(t, err, "failed to encode value of type func() for key invalid: gob NewTypeObject can't handle type: func()")
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no items", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		cache := NewRedisCache(client)
+
+		assert.NoError(t, cache.SetMulti(ctx, nil, 0))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestViaCep_RedisCache_Delete(t *testing.T) {
 	type dummy struct {
 		ID   int
@@ -393,3 +704,268 @@ func TestViaCep_RedisCache_Delete(t *testing.T) {
 		assert.False(t, found)
 	})
 }
+
+func TestViaCep_Cache_getMulti(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryCache()
+	defer memory.Close()
+
+	assert.NoError(t, memory.Set(ctx, "user:1", "alice", 0))
+
+	// plain hides the BatchCache methods of memory.
+	plain := struct{ Cache }{memory}
+	for _, cache := range []Cache{memory, plain} {
+		found := getMulti(ctx, cache, []string{"user:1", "user:2"}, func() any { return new(string) })
+		assert.Len(t, found, 1)
+		assert.Equal(t, "alice", *found["user:1"].(*string))
+	}
+}
+
+func TestViaCep_Cache_setMulti(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryCache()
+	defer memory.Close()
+
+	plain := struct{ Cache }{memory}
+	for _, cache := range []Cache{memory, plain} {
+		assert.NoError(t, setMulti(ctx, cache, map[string]any{"user:1": "alice", "user:2": "bob"}, 0))
+		assert.Len(t, getMulti(ctx, cache, []string{"user:1", "user:2"}, func() any { return new(string) }), 2)
+	}
+
+	err := setMulti(ctx, plain, map[string]any{"user:3": "carol", "invalid": make(chan int)}, 0)
+	assert.ErrorContains(t, err, "failed to encode value of type chan int")
+	assert.True(t, memory.Get(ctx, "user:3", new(string)))
+}
+
+func TestViaCep_NoopCache(t *testing.T) {
+	cache := noopCache{}
+
+	err := cache.Set(context.Background(), "user:1", "value", 0)
+	assert.NoError(t, err)
+
+	var dest string
+	found := cache.Get(context.Background(), "user:1", &dest)
+	assert.False(t, found)
+
+	err = cache.Delete(context.Background(), "user:1")
+	assert.NoError(t, err)
+}
+
+// goroutineCache is the previous memory cache implementation, which expired each entry with
+// its own sleeping goroutine. It is kept for benchmark comparison only.
+type goroutineCache struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func (c *goroutineCache) Get(_ context.Context, key string, dest any) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	serialized, exists := c.data[key]
+	if !exists {
+		return false
+	}
+
+	return gob.NewDecoder(bytes.NewBuffer(serialized)).Decode(dest) == nil
+}
+
+func (c *goroutineCache) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(value); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.data[key] = buffer.Bytes()
+
+	if ttl > 0 {
+		go func() {
+			time.Sleep(ttl)
+			_ = c.Delete(context.TODO(), key)
+		}()
+	}
+
+	return nil
+}
+
+func (c *goroutineCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.data, key)
+	return nil
+}
+
+func benchmarkCacheSet(b *testing.B, cache Cache) {
+	address := Address{Cep: "01001-000", Logradouro: "Praça da Sé", Localidade: "São Paulo", Uf: "SP"}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		_ = cache.Set(ctx, strconv.Itoa(i), address, time.Hour)
+	}
+}
+
+func benchmarkCacheGet(b *testing.B, cache Cache) {
+	address := Address{Cep: "01001-000", Logradouro: "Praça da Sé", Localidade: "São Paulo", Uf: "SP"}
+	ctx := context.Background()
+
+	const keys = 1024
+	for i := range keys {
+		_ = cache.Set(ctx, strconv.Itoa(i), address, time.Hour)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var dest Address
+		i := 0
+		for pb.Next() {
+			cache.Get(ctx, strconv.Itoa(i%keys), &dest)
+			i++
+		}
+	})
+}
+
+func BenchmarkViaCep_MemoryCache_Set(b *testing.B) {
+	cache := NewMemoryCache()
+	defer cache.Close()
+
+	benchmarkCacheSet(b, cache)
+}
+
+func BenchmarkViaCep_GoroutineCache_Set(b *testing.B) {
+	benchmarkCacheSet(b, &goroutineCache{data: make(map[string][]byte)})
+}
+
+func BenchmarkViaCep_MemoryCache_Get(b *testing.B) {
+	cache := NewMemoryCache()
+	defer cache.Close()
+
+	benchmarkCacheGet(b, cache)
+}
+
+func BenchmarkViaCep_GoroutineCache_Get(b *testing.B) {
+	benchmarkCacheGet(b, &goroutineCache{data: make(map[string][]byte)})
+}
+
+func TestViaCep_MemoryCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache(WithMaxEntries(2), WithJanitorInterval(0))
+
+	assert.NoError(t, cache.Set(ctx, "a", "alice", 0))
+	assert.NoError(t, cache.SetMulti(ctx, map[string]any{"b": "bob", "c": "carol"}, 0))
+	assert.Error(t, cache.Set(ctx, "d", make(chan int), 0))
+
+	var dest string
+	assert.True(t, cache.Get(ctx, "c", &dest))
+	assert.False(t, cache.Get(ctx, "a", &dest))
+	var number int
+	assert.False(t, cache.Get(ctx, "b", &number))
+	cache.GetMulti(ctx, []string{"b", "c", "x"}, func() any { return new(string) })
+
+	stats := cache.Stats()
+	assert.Equal(t, CacheStats{
+		Hits:           3,
+		Misses:         3,
+		Sets:           3,
+		SetErrors:      1,
+		Evictions:      1,
+		Entries:        2,
+		Bytes:          stats.Bytes,
+		DecodeFailures: 1,
+	}, stats)
+	assert.Positive(t, stats.Bytes)
+
+	t.Run("expired entries are evictions", func(t *testing.T) {
+		clock := newFakeClock()
+		cache := NewMemoryCache(WithMemoryCacheClock(clock), WithJanitorInterval(0))
+		assert.NoError(t, cache.Set(ctx, "a", "alice", time.Minute))
+		assert.NoError(t, cache.Set(ctx, "b", "bob", time.Minute))
+
+		clock.Advance(time.Minute)
+		assert.False(t, cache.Get(ctx, "a", &dest))
+		cache.deleteExpired()
+
+		stats := cache.Stats()
+		assert.Equal(t, uint64(2), stats.Evictions)
+		assert.Zero(t, stats.Entries)
+		assert.Zero(t, stats.Bytes)
+	})
+}
+
+func TestViaCep_RedisCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	client, mock := redismock.NewClientMock()
+	cache := NewRedisCache(client)
+
+	mock.ExpectSet("a", encodeGob(t, "alice"), 0).SetVal("OK")
+	mock.ExpectSet("b", encodeGob(t, "bob"), 0).SetErr(errors.New("error"))
+	mock.ExpectGet("a").SetVal(string(encodeGob(t, "alice")))
+	mock.ExpectGet("b").RedisNil()
+	mock.ExpectGet("c").SetVal("invalid data")
+	mock.ExpectMGet("a", "b").SetVal([]any{string(encodeGob(t, "alice")), nil})
+
+	assert.NoError(t, cache.Set(ctx, "a", "alice", 0))
+	assert.Error(t, cache.Set(ctx, "b", "bob", 0))
+	assert.Error(t, cache.Set(ctx, "c", func() {}, 0))
+
+	var dest string
+	cache.Get(ctx, "a", &dest)
+	cache.Get(ctx, "b", &dest)
+	cache.Get(ctx, "c", &dest)
+	cache.GetMulti(ctx, []string{"a", "b"}, func() any { return new(string) })
+
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 3, Sets: 1, SetErrors: 2, DecodeFailures: 1}, cache.Stats())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestViaCep_RedisCache_PurgeVersion(t *testing.T) {
+	ctx := context.Background()
+	pattern := versionedCacheKeyPattern("viacep", 0)
+
+	t.Run("deletes every batch", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		cache := NewRedisCache(client)
+
+		mock.ExpectScan(0, pattern, purgeScanCount).SetVal([]string{"viacep:a", "viacep:b"}, 42)
+		mock.ExpectDel("viacep:a", "viacep:b").SetVal(2)
+		mock.ExpectScan(42, pattern, purgeScanCount).SetVal([]string{}, 7)
+		mock.ExpectScan(7, pattern, purgeScanCount).SetVal([]string{"viacep:c"}, 0)
+		mock.ExpectDel("viacep:c").SetVal(1)
+
+		deleted, err := cache.PurgeVersion(ctx, "viacep", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), deleted)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		cache := NewRedisCache(client)
+
+		mock.ExpectScan(0, pattern, purgeScanCount).SetVal([]string{"viacep:a"}, 42)
+		mock.ExpectDel("viacep:a").SetVal(1)
+		mock.ExpectScan(42, pattern, purgeScanCount).SetErr(errors.New("error"))
+
+		deleted, err := cache.PurgeVersion(ctx, "viacep", 0)
+		assert.EqualError(t, err, "failed to scan cache keys: error")
+		assert.Equal(t, int64(1), deleted)
+	})
+
+	t.Run("delete error", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		cache := NewRedisCache(client)
+
+		mock.ExpectScan(0, pattern, purgeScanCount).SetVal([]string{"viacep:a"}, 0)
+		mock.ExpectDel("viacep:a").SetErr(errors.New("error"))
+
+		deleted, err := cache.PurgeVersion(ctx, "viacep", 0)
+		assert.EqualError(t, err, "failed to delete keys from cache: error")
+		assert.Zero(t, deleted)
+	})
+}
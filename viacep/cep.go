@@ -0,0 +1,53 @@
+package viacep
+
+import (
+	"fmt"
+	"strings"
+)
+
+const cepLength = 8
+
+// CEP is a normalised Brazilian postal code holding exactly 8 digits.
+type CEP string
+
+// ParseCEP normalises and validates a postal code.
+//
+// Surrounding whitespace and the usual separators ('.', '-' and inner spaces) are
+// ignored, so "01001-000", "01001000" and " 01.001-000 " all yield the same CEP.
+// Any other character, or a number of digits other than 8, results in an error
+// wrapping ErrInvalidCEP.
+func ParseCEP(value string) (CEP, error) {
+	var builder strings.Builder
+	builder.Grow(cepLength)
+
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			builder.WriteRune(r)
+		case r == '.' || r == '-' || r == ' ' || r == '\t':
+			continue
+		default:
+			return "", fmt.Errorf("%w: %q contains invalid character %q", ErrInvalidCEP, value, r)
+		}
+	}
+
+	if builder.Len() != cepLength {
+		return "", fmt.Errorf("%w: %q must have %d digits, got %d", ErrInvalidCEP, value, cepLength, builder.Len())
+	}
+
+	return CEP(builder.String()), nil
+}
+
+// String returns the CEP as 8 digits, e.g. "01001000".
+func (c CEP) String() string {
+	return string(c)
+}
+
+// Formatted returns the CEP in the conventional "01001-000" form.
+func (c CEP) Formatted() string {
+	if len(c) != cepLength {
+		return string(c)
+	}
+
+	return string(c[:5]) + "-" + string(c[5:])
+}
@@ -0,0 +1,65 @@
+package viacep
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViaCep_CEP_ParseCEP(t *testing.T) {
+	t.Run("valid inputs", func(t *testing.T) {
+		testCases := []string{
+			"01001-000",
+			"01001000",
+			" 01.001-000 ",
+			"01001 000",
+			"\t01001-000\t",
+		}
+
+		for _, tc := range testCases {
+			cep, err := ParseCEP(tc)
+			assert.NoError(t, err, tc)
+			assert.Equal(t, CEP("01001000"), cep, tc)
+		}
+	})
+
+	t.Run("invalid inputs", func(t *testing.T) {
+		testCases := []struct {
+			value    string
+			expected string
+		}{
+			{"", `invalid cep: "" must have 8 digits, got 0`},
+			{"0100100", `invalid cep: "0100100" must have 8 digits, got 7`},
+			{"010010000", `invalid cep: "010010000" must have 8 digits, got 9`},
+			{"0100100a", `invalid cep: "0100100a" contains invalid character 'a'`},
+			{"../x", `invalid cep: "../x" contains invalid character '/'`},
+			{"01001/000", `invalid cep: "01001/000" contains invalid character '/'`},
+			{"０１００１０００", `invalid cep: "０１００１０００" contains invalid character '０'`},
+		}
+
+		for _, tc := range testCases {
+			cep, err := ParseCEP(tc.value)
+			assert.ErrorIs(t, err, ErrInvalidCEP, tc.value)
+			assert.EqualError(t, err, tc.expected)
+			assert.Equal(t, CEP(""), cep)
+		}
+	})
+}
+
+func TestViaCep_CEP_String(t *testing.T) {
+	cep, err := ParseCEP("01001-000")
+	assert.NoError(t, err)
+	assert.Equal(t, "01001000", cep.String())
+}
+
+func TestViaCep_CEP_Formatted(t *testing.T) {
+	t.Run("valid cep", func(t *testing.T) {
+		cep, err := ParseCEP("01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, "01001-000", cep.Formatted())
+	})
+
+	t.Run("zero value", func(t *testing.T) {
+		assert.Equal(t, "", CEP("").Formatted())
+	})
+}
@@ -0,0 +1,309 @@
+package viacep
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConsecutiveFailures = 5
+	defaultOpenTimeout         = 30 * time.Second
+	defaultCircuitInterval     = 60 * time.Second
+	defaultHalfOpenRequests    = 1
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// StateClosed lets every request through while counting failures.
+	StateClosed CircuitState = iota
+	// StateOpen rejects every request with ErrCircuitOpen until the open timeout elapses.
+	StateOpen
+	// StateHalfOpen lets a limited number of probe requests through to decide whether to close again.
+	StateHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker is an HTTP decorator that stops sending requests to an API that keeps failing.
+//
+// Only errors matching ErrUpstreamUnavailable or ErrRateLimited count as failures; other
+// errors, such as a 400 or 404, show that the API is up and count as successes. Requests
+// whose context is canceled count as neither. While the circuit is open, Get fails
+// immediately with an error wrapping ErrCircuitOpen.
+type CircuitBreaker struct {
+	next HTTP
+
+	maxConsecutiveFailures int
+	failureRatio           float64
+	minRequests            int
+	interval               time.Duration
+	openTimeout            time.Duration
+	halfOpenRequests       int
+	onStateChange          func(from, to CircuitState)
+	clock                  Clock
+
+	mu                  sync.Mutex
+	state               CircuitState
+	generation          uint64
+	windowStart         time.Time
+	requests            int
+	failures            int
+	consecutiveFailures int
+	openedAt            time.Time
+	halfOpenInFlight    int
+	halfOpenSuccesses   int
+}
+
+// CircuitBreakerOption configures a CircuitBreaker created with NewCircuitBreaker.
+type CircuitBreakerOption func(*CircuitBreaker)
+
+type stateChange struct {
+	from, to CircuitState
+}
+
+// WithConsecutiveFailures opens the circuit after n consecutive failures. Defaults to 5;
+// a value <= 0 disables this trigger.
+func WithConsecutiveFailures(n int) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.maxConsecutiveFailures = n
+	}
+}
+
+// WithFailureRatio opens the circuit when at least minRequests were made in the current
+// interval and the ratio of failures among them reaches ratio (0 < ratio <= 1). Disabled by default.
+func WithFailureRatio(ratio float64, minRequests int) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.failureRatio = ratio
+		cb.minRequests = minRequests
+	}
+}
+
+// WithCircuitInterval sets how often the failure counts of a closed circuit are reset.
+// Defaults to one minute; a value <= 0 keeps counting until the circuit opens.
+func WithCircuitInterval(interval time.Duration) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.interval = interval
+	}
+}
+
+// WithOpenTimeout sets how long the circuit stays open before letting probe requests through.
+// Defaults to 30 seconds.
+func WithOpenTimeout(timeout time.Duration) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.openTimeout = timeout
+	}
+}
+
+// WithHalfOpenRequests sets how many probe requests may run while half-open; the circuit closes
+// once that many succeed. Defaults to 1.
+func WithHalfOpenRequests(n int) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.halfOpenRequests = max(n, 1)
+	}
+}
+
+// WithStateChangeHook registers a function called after every state transition, e.g. to
+// export metrics or log. It is called synchronously by the request that caused the transition.
+func WithStateChangeHook(hook func(from, to CircuitState)) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.onStateChange = hook
+	}
+}
+
+// WithCircuitClock sets the clock used to measure the open timeout and the counting interval.
+func WithCircuitClock(clock Clock) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.clock = clock
+	}
+}
+
+func NewCircuitBreaker(next HTTP, opts ...CircuitBreakerOption) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		next:                   next,
+		maxConsecutiveFailures: defaultConsecutiveFailures,
+		interval:               defaultCircuitInterval,
+		openTimeout:            defaultOpenTimeout,
+		halfOpenRequests:       defaultHalfOpenRequests,
+		clock:                  systemClock{},
+	}
+
+	for _, opt := range opts {
+		opt(cb)
+	}
+
+	cb.windowStart = cb.clock.Now()
+	return cb
+}
+
+func (cb *CircuitBreaker) Get(ctx context.Context, url string, dest any) error {
+	generation, err := cb.allow()
+	if err != nil {
+		return err
+	}
+
+	err = cb.next.Get(ctx, url, dest)
+	cb.record(generation, err)
+	return err
+}
+
+// State returns the current state of the circuit.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == StateOpen && cb.clock.Now().Sub(cb.openedAt) >= cb.openTimeout {
+		return StateHalfOpen
+	}
+
+	return cb.state
+}
+
+func (cb *CircuitBreaker) allow() (uint64, error) {
+	var change *stateChange
+
+	cb.mu.Lock()
+	defer func() {
+		cb.mu.Unlock()
+		cb.notify(change)
+	}()
+
+	now := cb.clock.Now()
+
+	switch cb.state {
+	case StateOpen:
+		if now.Sub(cb.openedAt) < cb.openTimeout {
+			return 0, ErrCircuitOpen
+		}
+
+		change = cb.setState(StateHalfOpen, now)
+		cb.halfOpenInFlight++
+	case StateHalfOpen:
+		if cb.halfOpenInFlight+cb.halfOpenSuccesses >= cb.halfOpenRequests {
+			return 0, ErrCircuitOpen
+		}
+
+		cb.halfOpenInFlight++
+	default:
+		if cb.interval > 0 && now.Sub(cb.windowStart) >= cb.interval {
+			cb.resetCounts(now)
+		}
+	}
+
+	return cb.generation, nil
+}
+
+// record counts the outcome of a request allowed in generation. A canceled request says
+// nothing about the API, so it only frees its half-open slot.
+func (cb *CircuitBreaker) record(generation uint64, err error) {
+	var change *stateChange
+
+	cb.mu.Lock()
+	defer func() {
+		cb.mu.Unlock()
+		cb.notify(change)
+	}()
+
+	// The request was allowed before the last transition; its outcome no longer matters.
+	if generation != cb.generation {
+		return
+	}
+
+	now := cb.clock.Now()
+	canceled := errors.Is(err, context.Canceled)
+	failure := isCircuitFailure(err)
+
+	switch cb.state {
+	case StateHalfOpen:
+		cb.halfOpenInFlight--
+		if canceled {
+			return
+		}
+
+		if failure {
+			change = cb.setState(StateOpen, now)
+			return
+		}
+
+		cb.halfOpenSuccesses++
+		if cb.halfOpenSuccesses >= cb.halfOpenRequests {
+			change = cb.setState(StateClosed, now)
+		}
+	case StateClosed:
+		if canceled {
+			return
+		}
+
+		cb.requests++
+		if !failure {
+			cb.consecutiveFailures = 0
+			return
+		}
+
+		cb.failures++
+		cb.consecutiveFailures++
+		if cb.shouldTrip() {
+			change = cb.setState(StateOpen, now)
+		}
+	}
+}
+
+func (cb *CircuitBreaker) shouldTrip() bool {
+	if cb.maxConsecutiveFailures > 0 && cb.consecutiveFailures >= cb.maxConsecutiveFailures {
+		return true
+	}
+
+	if cb.failureRatio > 0 && cb.requests >= max(cb.minRequests, 1) {
+		return float64(cb.failures)/float64(cb.requests) >= cb.failureRatio
+	}
+
+	return false
+}
+
+// setState moves the circuit to state and starts a new generation. It must be called with cb.mu held.
+func (cb *CircuitBreaker) setState(state CircuitState, now time.Time) *stateChange {
+	change := &stateChange{from: cb.state, to: state}
+
+	cb.state = state
+	cb.generation++
+	cb.halfOpenInFlight = 0
+	cb.halfOpenSuccesses = 0
+	cb.resetCounts(now)
+
+	if state == StateOpen {
+		cb.openedAt = now
+	}
+
+	return change
+}
+
+func (cb *CircuitBreaker) resetCounts(now time.Time) {
+	cb.windowStart = now
+	cb.requests = 0
+	cb.failures = 0
+	cb.consecutiveFailures = 0
+}
+
+func (cb *CircuitBreaker) notify(change *stateChange) {
+	if change != nil && cb.onStateChange != nil {
+		cb.onStateChange(change.from, change.to)
+	}
+}
+
+func isCircuitFailure(err error) bool {
+	return errors.Is(err, ErrUpstreamUnavailable) || errors.Is(err, ErrRateLimited)
+}
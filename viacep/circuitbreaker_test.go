@@ -0,0 +1,296 @@
+package viacep
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// toggleServer answers 200 while healthy and 503 otherwise, counting the requests it receives.
+type toggleServer struct {
+	*httptest.Server
+	healthy atomic.Bool
+	hits    atomic.Int32
+}
+
+func newToggleServer(t *testing.T) *toggleServer {
+	t.Helper()
+
+	s := &toggleServer{}
+	s.healthy.Store(true)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		if !s.healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"cep":"01001-000"}`))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+type transition struct {
+	from, to CircuitState
+}
+
+func TestViaCep_CircuitState_String(t *testing.T) {
+	assert.Equal(t, "closed", StateClosed.String())
+	assert.Equal(t, "open", StateOpen.String())
+	assert.Equal(t, "half-open", StateHalfOpen.String())
+	assert.Equal(t, "unknown", CircuitState(42).String())
+}
+
+func TestViaCep_CircuitBreaker_Get(t *testing.T) {
+	ctx := context.Background()
+
+	newBreaker := func(clock Clock, opts ...CircuitBreakerOption) (*CircuitBreaker, *[]transition) {
+		var transitions []transition
+		opts = append([]CircuitBreakerOption{
+			WithCircuitClock(clock),
+			WithStateChangeHook(func(from, to CircuitState) {
+				transitions = append(transitions, transition{from, to})
+			}),
+		}, opts...)
+
+		return NewCircuitBreaker(NewHTTPClient(0), opts...), &transitions
+	}
+
+	t.Run("opens after consecutive failures and fails fast", func(t *testing.T) {
+		srv := newToggleServer(t)
+		clock := newFakeClock()
+		cb, transitions := newBreaker(clock, WithConsecutiveFailures(3))
+
+		srv.healthy.Store(false)
+		for range 3 {
+			err := cb.Get(ctx, srv.URL, &Address{})
+			assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+			assert.NotErrorIs(t, err, ErrCircuitOpen)
+		}
+
+		assert.Equal(t, StateOpen, cb.State())
+		assert.Equal(t, []transition{{StateClosed, StateOpen}}, *transitions)
+
+		err := cb.Get(ctx, srv.URL, &Address{})
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+		assert.EqualError(t, err, "circuit breaker is open: upstream unavailable")
+		assert.Equal(t, int32(3), srv.hits.Load())
+	})
+
+	t.Run("a success resets consecutive failures", func(t *testing.T) {
+		srv := newToggleServer(t)
+		cb, _ := newBreaker(newFakeClock(), WithConsecutiveFailures(2))
+
+		srv.healthy.Store(false)
+		_ = cb.Get(ctx, srv.URL, &Address{})
+		srv.healthy.Store(true)
+		assert.NoError(t, cb.Get(ctx, srv.URL, &Address{}))
+		srv.healthy.Store(false)
+		_ = cb.Get(ctx, srv.URL, &Address{})
+
+		assert.Equal(t, StateClosed, cb.State())
+	})
+
+	t.Run("client errors do not count as failures", func(t *testing.T) {
+		srv := newToggleServer(t)
+		cb, _ := newBreaker(newFakeClock(), WithConsecutiveFailures(1))
+
+		for range 3 {
+			assert.ErrorIs(t, cb.Get(ctx, srv.URL+"/missing", &Address{}), ErrNotFound)
+		}
+
+		assert.Equal(t, StateClosed, cb.State())
+	})
+
+	t.Run("half-open probe closes the circuit on success", func(t *testing.T) {
+		srv := newToggleServer(t)
+		clock := newFakeClock()
+		cb, transitions := newBreaker(clock, WithConsecutiveFailures(1), WithOpenTimeout(10*time.Second))
+
+		srv.healthy.Store(false)
+		_ = cb.Get(ctx, srv.URL, &Address{})
+		assert.Equal(t, StateOpen, cb.State())
+
+		clock.Advance(9 * time.Second)
+		assert.ErrorIs(t, cb.Get(ctx, srv.URL, &Address{}), ErrCircuitOpen)
+
+		clock.Advance(time.Second)
+		assert.Equal(t, StateHalfOpen, cb.State())
+
+		srv.healthy.Store(true)
+		assert.NoError(t, cb.Get(ctx, srv.URL, &Address{}))
+		assert.Equal(t, StateClosed, cb.State())
+		assert.Equal(t, []transition{
+			{StateClosed, StateOpen},
+			{StateOpen, StateHalfOpen},
+			{StateHalfOpen, StateClosed},
+		}, *transitions)
+	})
+
+	t.Run("half-open probe reopens the circuit on failure", func(t *testing.T) {
+		srv := newToggleServer(t)
+		clock := newFakeClock()
+		cb, transitions := newBreaker(clock, WithConsecutiveFailures(1), WithOpenTimeout(10*time.Second))
+
+		srv.healthy.Store(false)
+		_ = cb.Get(ctx, srv.URL, &Address{})
+		clock.Advance(10 * time.Second)
+
+		assert.ErrorIs(t, cb.Get(ctx, srv.URL, &Address{}), ErrUpstreamUnavailable)
+		assert.Equal(t, StateOpen, cb.State())
+		assert.ErrorIs(t, cb.Get(ctx, srv.URL, &Address{}), ErrCircuitOpen)
+		assert.Equal(t, int32(2), srv.hits.Load())
+		assert.Equal(t, []transition{
+			{StateClosed, StateOpen},
+			{StateOpen, StateHalfOpen},
+			{StateHalfOpen, StateOpen},
+		}, *transitions)
+	})
+
+	t.Run("half-open limits probe requests", func(t *testing.T) {
+		next := &blockingHTTP{release: make(chan struct{}), started: make(chan struct{}, 1)}
+		clock := newFakeClock()
+		cb := NewCircuitBreaker(next, WithCircuitClock(clock), WithConsecutiveFailures(1), WithHalfOpenRequests(1))
+
+		next.err = ErrUpstreamUnavailable
+		close(next.release)
+		_ = cb.Get(ctx, "", nil)
+		<-next.started
+
+		clock.Advance(defaultOpenTimeout)
+		next.err = nil
+		next.release = make(chan struct{})
+
+		done := make(chan error)
+		go func() { done <- cb.Get(ctx, "", nil) }()
+		<-next.started
+
+		assert.ErrorIs(t, cb.Get(ctx, "", nil), ErrCircuitOpen)
+
+		close(next.release)
+		assert.NoError(t, <-done)
+		assert.Equal(t, StateClosed, cb.State())
+	})
+
+	t.Run("canceled requests count as neither success nor failure", func(t *testing.T) {
+		next := &blockingHTTP{release: make(chan struct{}), started: make(chan struct{}, 1)}
+		close(next.release)
+		cb := NewCircuitBreaker(next, WithCircuitClock(newFakeClock()), WithConsecutiveFailures(2))
+
+		next.err = ErrUpstreamUnavailable
+		_ = cb.Get(ctx, "", nil)
+		<-next.started
+		next.err = &APIError{Err: context.Canceled}
+		assert.ErrorIs(t, cb.Get(ctx, "", nil), context.Canceled)
+		<-next.started
+		next.err = ErrUpstreamUnavailable
+		_ = cb.Get(ctx, "", nil)
+		<-next.started
+
+		assert.Equal(t, StateOpen, cb.State())
+	})
+
+	t.Run("half-open probe canceled keeps the circuit half-open", func(t *testing.T) {
+		next := &blockingHTTP{release: make(chan struct{}), started: make(chan struct{}, 1)}
+		close(next.release)
+		clock := newFakeClock()
+		var transitions []transition
+		cb := NewCircuitBreaker(next, WithCircuitClock(clock), WithConsecutiveFailures(1),
+			WithStateChangeHook(func(from, to CircuitState) { transitions = append(transitions, transition{from, to}) }))
+
+		next.err = ErrUpstreamUnavailable
+		_ = cb.Get(ctx, "", nil)
+		<-next.started
+		clock.Advance(defaultOpenTimeout)
+
+		next.err = &APIError{Err: context.Canceled}
+		assert.ErrorIs(t, cb.Get(ctx, "", nil), context.Canceled)
+		<-next.started
+		assert.Equal(t, StateHalfOpen, cb.State())
+
+		next.err = nil
+		assert.NoError(t, cb.Get(ctx, "", nil), "the canceled probe freed its slot")
+		<-next.started
+		assert.Equal(t, StateClosed, cb.State())
+		assert.Equal(t, []transition{
+			{StateClosed, StateOpen},
+			{StateOpen, StateHalfOpen},
+			{StateHalfOpen, StateClosed},
+		}, transitions)
+	})
+
+	t.Run("opens on failure ratio", func(t *testing.T) {
+		srv := newToggleServer(t)
+		cb, _ := newBreaker(newFakeClock(), WithConsecutiveFailures(0), WithFailureRatio(0.5, 4))
+
+		srv.healthy.Store(false)
+		_ = cb.Get(ctx, srv.URL, &Address{})
+		srv.healthy.Store(true)
+		_ = cb.Get(ctx, srv.URL, &Address{})
+		srv.healthy.Store(false)
+		_ = cb.Get(ctx, srv.URL, &Address{})
+		assert.Equal(t, StateClosed, cb.State(), "below minimum requests")
+
+		srv.healthy.Store(true)
+		_ = cb.Get(ctx, srv.URL, &Address{})
+		assert.Equal(t, StateClosed, cb.State(), "ratio reached only on failure")
+
+		srv.healthy.Store(false)
+		_ = cb.Get(ctx, srv.URL, &Address{})
+		assert.Equal(t, StateOpen, cb.State())
+	})
+
+	t.Run("counts reset every interval", func(t *testing.T) {
+		srv := newToggleServer(t)
+		clock := newFakeClock()
+		cb, _ := newBreaker(clock, WithConsecutiveFailures(2), WithCircuitInterval(time.Minute))
+
+		srv.healthy.Store(false)
+		_ = cb.Get(ctx, srv.URL, &Address{})
+		clock.Advance(time.Minute)
+		_ = cb.Get(ctx, srv.URL, &Address{})
+
+		assert.Equal(t, StateClosed, cb.State())
+	})
+
+	t.Run("with viacep", func(t *testing.T) {
+		srv := newToggleServer(t)
+		cb := NewCircuitBreaker(NewHTTPClient(0), WithCircuitClock(newFakeClock()), WithConsecutiveFailures(1))
+		v := New(WithHTTP(cb), WithBaseURL(srv.URL), WithNoCache())
+
+		srv.healthy.Store(false)
+		_, err := v.Cep(ctx, "01001000")
+		assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+
+		_, err = v.Cep(ctx, "01001000")
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		assert.Equal(t, int32(1), srv.hits.Load())
+	})
+}
+
+// blockingHTTP blocks every Get until release is closed, signalling started when a call begins.
+type blockingHTTP struct {
+	release chan struct{}
+	started chan struct{}
+	err     error
+}
+
+func (b *blockingHTTP) Get(_ context.Context, _ string, _ any) error {
+	b.started <- struct{}{}
+	<-b.release
+	return b.err
+}
@@ -3,6 +3,9 @@ package viacep
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 )
 
 const urlBase = "https://viacep.com.br"
@@ -12,11 +15,13 @@ type Service interface {
 	//
 	// Parameters:
 	//   - ctx: The context to manage the request lifecycle, such as timeouts or cancellations.
-	//   - cep: The postal code (CEP) for which the address information will be retrieved.
+	//   - cep: The postal code (CEP) for which the address information will be retrieved. It is
+	//          normalised with ParseCEP, so "01001-000" and "01001000" are equivalent.
 	//
 	// Returns:
 	//   - *Address: A pointer to the Address object with the address data.
-	//   - error: If an error occurs during the request, it will be returned. Otherwise, nil will be returned.
+	//   - error: ErrInvalidCEP if the CEP is malformed (no request is sent), ErrCepNotFound if it does
+	//            not exist, or any other error that occurs during the request. Otherwise, nil will be returned.
 	Cep(ctx context.Context, cep string) (*Address, error)
 
 	// Addresses retrieves a list of addresses based on the provided parameters: state (uf), city (cidade), and street (logradouro).
@@ -27,70 +32,413 @@ type Service interface {
 	//   - cidade: The name of the city for which the address search will be conducted.
 	//   - logradouro: The name of the street or address for which the address search will be conducted.
 	//
+	// The uf must be one of the 27 federative units and both cidade and logradouro must have at least
+	// 3 characters. Each value is path-escaped, so accents, spaces and slashes can be passed as is.
+	//
 	// Returns:
 	//   - []Address: A list of addresses found.
-	//   - error: If an error occurs during the request, it will be returned. Otherwise, nil will be returned.
+	//   - error: A *ValidationError (matching ErrInvalidSearch) if a parameter is rejected before any
+	//            request is sent, or any error that occurs during the request. Otherwise, nil will be returned.
 	Addresses(ctx context.Context, uf, cidade, logradouro string) ([]Address, error)
 }
 
 type Address struct {
-	Cep         string `json:"cep"`
-	Logradouro  string `json:"logradouro"`
-	Complemento string `json:"complemento"`
-	Unidade     string `json:"unidade"`
-	Bairro      string `json:"bairro"`
-	Localidade  string `json:"localidade"`
-	Uf          string `json:"uf"`
-	Estado      string `json:"estado"`
-	Regiao      string `json:"regiao"`
-	Ibge        string `json:"ibge"`
-	Gia         string `json:"gia"`
-	Ddd         string `json:"ddd"`
-	Siafi       string `json:"siafi"`
+	Cep         string `json:"cep" xml:"cep"`
+	Logradouro  string `json:"logradouro" xml:"logradouro"`
+	Complemento string `json:"complemento" xml:"complemento"`
+	Unidade     string `json:"unidade" xml:"unidade"`
+	Bairro      string `json:"bairro" xml:"bairro"`
+	Localidade  string `json:"localidade" xml:"localidade"`
+	Uf          string `json:"uf" xml:"uf"`
+	Estado      string `json:"estado" xml:"estado"`
+	Regiao      string `json:"regiao" xml:"regiao"`
+	Ibge        string `json:"ibge" xml:"ibge"`
+	Gia         string `json:"gia" xml:"gia"`
+	Ddd         string `json:"ddd" xml:"ddd"`
+	Siafi       string `json:"siafi" xml:"siafi"`
+}
+
+// cepResponse is the payload returned by the /ws/{cep}/json/ endpoint. Unknown CEPs
+// are answered with HTTP 200 and {"erro": true} (older deployments use "true").
+type cepResponse struct {
+	Address
+	Erro erroFlag `json:"erro"`
+}
+
+type erroFlag bool
+
+func (f *erroFlag) UnmarshalJSON(data []byte) error {
+	*f = strings.Trim(string(data), `"`) == "true"
+	return nil
+}
+
+// cepEntry is the value cached for a CEP. Unknown CEPs are cached as tombstones with
+// NotFound set, so that repeated lookups do not reach the API. FreshFor overrides the cache TTL
+// of the client for entries stored by Warm with a TTL of their own.
+type cepEntry struct {
+	Version  int           `json:"version,omitempty"`
+	Address  Address       `json:"address"`
+	NotFound bool          `json:"notFound,omitempty"`
+	StoredAt time.Time     `json:"storedAt"`
+	FreshFor time.Duration `json:"freshFor,omitempty"`
+}
+
+// addressesEntry is the value cached for an address search.
+type addressesEntry struct {
+	Version   int       `json:"version,omitempty"`
+	Addresses []Address `json:"addresses"`
+}
+
+// LookupInfo describes how a lookup made with CepWithInfo was served.
+type LookupInfo struct {
+	// Cached reports whether the address was served from the cache.
+	Cached bool
+	// Stale reports whether the cached address is older than the cache TTL.
+	Stale bool
+	// Revalidating reports whether a background refresh of a stale address was started.
+	Revalidating bool
+	// StoredAt is when the address was fetched from the API. It is zero for entries cached
+	// by older versions of this package.
+	StoredAt time.Time
 }
 
 type ViaCep struct {
-	httpClient HTTP
-	cache      Cache
+	httpClient           HTTP
+	cache                Cache
+	baseURL              string
+	cacheTTL             time.Duration
+	negativeCacheTTL     time.Duration
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+	clock                Clock
+	rateLimiter          RateLimiter
+	format               Format
+	cacheNamespace       string
+	ownedCache           *MemoryCache
+	cepFlight            flightGroup[cepEntry]
+	addressesFlight      flightGroup[[]Address]
+	stats                cacheCounters
 }
 
-func New(httpClient HTTP) *ViaCep {
-	return &ViaCep{
-		httpClient: httpClient,
-		cache:      newMemoryCache(),
+// New creates a ViaCep client configured by the given options. Without options it talks to
+// https://viacep.com.br through NewHTTPClient(1) and caches lookups in a MemoryCache for one
+// hour (five minutes for unknown CEPs); call Close to stop its janitor when the client is no longer needed.
+func New(opts ...Option) *ViaCep {
+	v := &ViaCep{
+		baseURL:          urlBase,
+		cacheTTL:         cacheTTL,
+		negativeCacheTTL: negativeCacheTTL,
+		clock:            systemClock{},
+		format:           FormatJSON,
+		cacheNamespace:   defaultCacheNamespace,
 	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	if v.httpClient == nil {
+		v.httpClient = NewHTTPClient(defaultMaxRetry)
+	}
+
+	if v.cache == nil {
+		v.ownedCache = NewMemoryCache(WithMemoryCacheClock(v.clock))
+		v.cache = v.ownedCache
+	}
+
+	return v
+}
+
+// NewWithHTTP creates a ViaCep client that uses the given HTTP client and the default settings.
+//
+// Deprecated: use New(WithHTTP(httpClient)).
+func NewWithHTTP(httpClient HTTP) *ViaCep {
+	return New(WithHTTP(httpClient))
+}
+
+// Close stops the background work of the default in-memory cache. Caches supplied through
+// WithCache are left to the caller.
+func (v *ViaCep) Close() error {
+	if v.ownedCache != nil {
+		return v.ownedCache.Close()
+	}
+
+	return nil
 }
 
 func (v *ViaCep) Cep(ctx context.Context, cep string) (*Address, error) {
-	key := cacheKey(cep)
+	address, _, err := v.CepWithInfo(ctx, cep)
+	return address, err
+}
 
-	var address Address
-	if found := v.cache.Get(ctx, key, &address); found {
-		return &address, nil
+// CepWithInfo behaves like Cep and also reports how the address was served, in particular
+// whether it is a stale copy returned because of WithStaleWhileRevalidate or WithStaleIfError.
+func (v *ViaCep) CepWithInfo(ctx context.Context, cep string) (*Address, LookupInfo, error) {
+	parsed, err := ParseCEP(cep)
+	if err != nil {
+		return nil, LookupInfo{}, err
 	}
 
-	url := fmt.Sprintf("%s/ws/%s/json/", urlBase, cep)
-	if err := v.httpClient.Get(ctx, url, &address); err != nil {
-		return nil, err
+	key := v.cacheKey(parsed.String())
+
+	var entry cepEntry
+	found := v.cache.Get(ctx, key, &entry)
+	if v.cachedCep(found, entry) {
+		return v.serveCached(ctx, parsed, key, entry, v.storeCep)
 	}
 
-	_ = v.cache.Set(ctx, key, address, cacheTTL)
-	return &address, nil
+	return v.serveFresh(ctx, parsed, key, v.storeCep)
 }
 
 func (v *ViaCep) Addresses(ctx context.Context, uf, cidade, logradouro string) ([]Address, error) {
-	key := cacheKey(uf, cidade, logradouro)
+	query, err := newAddressQuery(uf, cidade, logradouro)
+	if err != nil {
+		return nil, err
+	}
+
+	key := v.cacheKey(query.uf, query.cidade, query.logradouro)
+
+	var entry addressesEntry
+	found := v.cache.Get(ctx, key, &entry)
+	if v.stats.read(found && entry.Version == CacheSchemaVersion) {
+		return entry.Addresses, nil
+	}
+
+	addresses, err := v.addressesFlight.do(ctx, key, func(ctx context.Context) ([]Address, error) {
+		addresses, err := fetch(ctx, v, query.path(), true, parseAddresses)
+		if err != nil {
+			return nil, err
+		}
 
-	var addresses []Address
-	if found := v.cache.Get(ctx, key, &addresses); found {
+		v.stats.write(v.cache.Set(ctx, key, addressesEntry{Version: CacheSchemaVersion, Addresses: addresses}, v.cacheTTL))
 		return addresses, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Clone(addresses), nil
+}
+
+// serveCached answers a lookup from a cached entry, refreshing it first or in the background
+// when it is past the cache TTL. Entries refreshed in the foreground are written with store.
+func (v *ViaCep) serveCached(ctx context.Context, cep CEP, key string, entry cepEntry, store cepStore) (*Address, LookupInfo, error) {
+	info := LookupInfo{Cached: true, StoredAt: entry.StoredAt}
+	age := v.clock.Now().Sub(entry.StoredAt)
+
+	switch {
+	case v.isFresh(entry):
+		address, err := entry.result(cep)
+		return address, info, err
+	case age < v.freshFor(entry)+v.staleWhileRevalidate:
+		v.revalidate(ctx, cep, key)
+
+		info.Stale, info.Revalidating = true, true
+		address, err := entry.result(cep)
+		return address, info, err
+	case age < v.freshFor(entry)+v.staleIfError:
+		fresh, err := v.loadCep(ctx, cep, key, store)
+		if err != nil && ctx.Err() == nil {
+			info.Stale = true
+			address, _ := entry.result(cep)
+			return address, info, nil
+		}
+
+		if err != nil {
+			return nil, LookupInfo{}, err
+		}
+
+		address, err := fresh.result(cep)
+		return address, LookupInfo{StoredAt: fresh.StoredAt}, err
+	default:
+		return v.serveFresh(ctx, cep, key, store)
+	}
+}
+
+// isFresh reports whether entry can be served without contacting the API. Tombstones and entries
+// written before StoredAt existed are fresh until the cache evicts them.
+func (v *ViaCep) isFresh(entry cepEntry) bool {
+	return entry.NotFound || entry.StoredAt.IsZero() || v.clock.Now().Sub(entry.StoredAt) < v.freshFor(entry)
+}
+
+// freshFor returns how long entry can be served after it was stored without contacting the API.
+func (v *ViaCep) freshFor(entry cepEntry) time.Duration {
+	if entry.FreshFor > 0 {
+		return entry.FreshFor
+	}
+
+	return v.cacheTTL
+}
+
+func (v *ViaCep) serveFresh(ctx context.Context, cep CEP, key string, store cepStore) (*Address, LookupInfo, error) {
+	entry, err := v.loadCep(ctx, cep, key, store)
+	if err != nil {
+		return nil, LookupInfo{}, err
+	}
+
+	address, err := entry.result(cep)
+	return address, LookupInfo{StoredAt: entry.StoredAt}, err
+}
+
+// loadCep fetches a CEP from the API and caches it with store, sharing the request with
+// concurrent callers of the same key.
+func (v *ViaCep) loadCep(ctx context.Context, cep CEP, key string, store cepStore) (cepEntry, error) {
+	return v.cepFlight.do(ctx, key, func(ctx context.Context) (cepEntry, error) {
+		entry, err := v.fetchCep(ctx, cep)
+		if err != nil {
+			return cepEntry{}, err
+		}
+
+		store(ctx, key, entry)
+		return entry, nil
+	})
+}
+
+// revalidate refreshes a stale entry in the background. Failures are ignored: the stale
+// entry stays in the cache until its hard TTL.
+func (v *ViaCep) revalidate(ctx context.Context, cep CEP, key string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), revalidateTimeout)
+
+	go func() {
+		defer cancel()
+		_, _ = v.loadCep(ctx, cep, key, v.storeCep)
+	}()
+}
+
+func (v *ViaCep) fetchCep(ctx context.Context, cep CEP) (cepEntry, error) {
+	resp, err := fetch(ctx, v, string(cep), false, parseCep)
+	if err != nil {
+		return cepEntry{}, err
+	}
+
+	if resp.Erro {
+		return cepEntry{Version: CacheSchemaVersion, NotFound: true, StoredAt: v.clock.Now()}, nil
+	}
+
+	return cepEntry{Version: CacheSchemaVersion, Address: resp.Address, StoredAt: v.clock.Now()}, nil
+}
+
+// cepStore writes a CEP entry fetched from the API to the cache.
+type cepStore func(ctx context.Context, key string, entry cepEntry)
+
+// storeCep caches entry. Addresses are kept past the TTL for as long as they may be served
+// stale; tombstones expire after the negative TTL.
+func (v *ViaCep) storeCep(ctx context.Context, key string, entry cepEntry) {
+	if ttl, ok := v.cepEntryTTL(entry); ok {
+		v.stats.write(v.cache.Set(ctx, key, entry, ttl))
+	}
+}
+
+// cepEntryTTL returns how long entry is kept in the cache, or false if it is not cached at all.
+func (v *ViaCep) cepEntryTTL(entry cepEntry) (time.Duration, bool) {
+	if entry.NotFound {
+		return v.negativeCacheTTL, v.negativeCacheTTL > 0
+	}
+
+	return v.cepTTL(), true
+}
+
+// cepTTL is how long a CEP lookup is kept in the cache: past the cache TTL for as long as it can
+// still be served stale.
+func (v *ViaCep) cepTTL() time.Duration {
+	return v.cacheTTL + max(v.staleWhileRevalidate, v.staleIfError)
+}
+
+// cachedCep reports whether a cache read of a CEP found an entry that can be used, i.e. one of
+// the current schema version, and counts the read. Entries of other versions count as misses so
+// that they are refetched and overwritten.
+func (v *ViaCep) cachedCep(found bool, entry cepEntry) bool {
+	usable := v.stats.read(found && entry.Version == CacheSchemaVersion)
+	if usable && entry.NotFound {
+		v.stats.negativeHits.Add(1)
+	}
+
+	return usable
+}
+
+// cacheKey returns the key of values in the namespace of the client at the current schema version.
+func (v *ViaCep) cacheKey(values ...string) string {
+	return versionedCacheKey(v.cacheNamespace, CacheSchemaVersion, values...)
+}
+
+func (e cepEntry) result(cep CEP) (*Address, error) {
+	if e.NotFound {
+		return nil, fmt.Errorf("%w: %s", ErrCepNotFound, cep)
+	}
+
+	address := e.Address
+	return &address, nil
+}
+
+// CepRaw returns the ViaCEP response for cep in the given format, byte for byte. The payload
+// ViaCEP sends for an unknown CEP is returned as is, without an error. Raw responses are not cached.
+func (v *ViaCep) CepRaw(ctx context.Context, cep string, format Format) ([]byte, error) {
+	parsed, err := ParseCEP(cep)
+	if err != nil {
+		return nil, err
 	}
 
-	url := fmt.Sprintf("%s/ws/%s/%s/%s/json/", urlBase, uf, cidade, logradouro)
-	if err := v.httpClient.Get(ctx, url, &addresses); err != nil {
+	return v.raw(ctx, string(parsed), false, format)
+}
+
+// AddressesRaw returns the ViaCEP response of an address search in the given format, byte for
+// byte. Only FormatJSON and FormatXML are served for searches. Raw responses are not cached.
+func (v *ViaCep) AddressesRaw(ctx context.Context, uf, cidade, logradouro string, format Format) ([]byte, error) {
+	query, err := newAddressQuery(uf, cidade, logradouro)
+	if err != nil {
 		return nil, err
 	}
 
-	_ = v.cache.Set(ctx, key, addresses, cacheTTL)
-	return addresses, nil
+	return v.raw(ctx, query.path(), true, format)
+}
+
+func (v *ViaCep) raw(ctx context.Context, path string, search bool, format Format) ([]byte, error) {
+	if err := format.validate(search); err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if err := v.get(ctx, v.formatURL(path, format), &body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// fetch requests path in the configured format. JSON is decoded by the HTTP client, as before
+// formats were introduced; other formats are fetched raw and decoded by parse.
+func fetch[T any](ctx context.Context, v *ViaCep, path string, search bool, parse func(Format, []byte) (T, error)) (T, error) {
+	var result T
+	if err := v.format.validate(search); err != nil {
+		return result, err
+	}
+
+	if v.format == FormatJSON {
+		err := v.get(ctx, v.formatURL(path, FormatJSON), &result)
+		return result, err
+	}
+
+	var body []byte
+	if err := v.get(ctx, v.formatURL(path, v.format), &body); err != nil {
+		return result, err
+	}
+
+	return parse(v.format, body)
+}
+
+func (v *ViaCep) formatURL(path string, format Format) string {
+	return fmt.Sprintf("%s/ws/%s/%s/", v.baseURL, path, format)
+}
+
+// get waits for the rate limiter, if any, and fetches url into dest.
+func (v *ViaCep) get(ctx context.Context, url string, dest any) error {
+	if v.rateLimiter != nil {
+		if err := v.rateLimiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		ctx = withRetryLimiter(ctx, v.rateLimiter)
+	}
+
+	return v.httpClient.Get(ctx, url, dest)
 }
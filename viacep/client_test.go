@@ -2,19 +2,291 @@ package viacep
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/go-redis/redismock/v8"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestViaCep_Client_Cep(t *testing.T) {
+	t.Run("address found", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/ws/01001000/json/", r.URL.Path)
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"cep": "01001-000", "logradouro": "Praça da Sé", "uf": "SP"}`))
+		}))
+		defer srv.Close()
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+
+		address, err := c.Cep(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, &Address{Cep: "01001-000", Logradouro: "Praça da Sé", Uf: "SP"}, address)
+	})
+
+	t.Run("normalised cep shares cache entry", func(t *testing.T) {
+		var hits atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			assert.Equal(t, "/ws/01001000/json/", r.URL.Path)
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"cep": "01001-000"}`))
+		}))
+		defer srv.Close()
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+
+		for _, cep := range []string{"01001-000", "01001000", " 01.001-000 "} {
+			address, err := c.Cep(context.Background(), cep)
+			assert.NoError(t, err)
+			assert.Equal(t, &Address{Cep: "01001-000"}, address)
+		}
+
+		assert.Equal(t, int32(1), hits.Load())
+	})
+
+	t.Run("invalid cep", func(t *testing.T) {
+		var hits atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			hits.Add(1)
+		}))
+		defer srv.Close()
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+
+		for _, cep := range []string{"0100100a", "../x", "123"} {
+			address, err := c.Cep(context.Background(), cep)
+			assert.Nil(t, address)
+			assert.ErrorIs(t, err, ErrInvalidCEP)
+		}
+
+		assert.Equal(t, int32(0), hits.Load())
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+
+		address, err := c.Cep(context.Background(), "01001000")
+		assert.Nil(t, address)
+		assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, srv.URL+"/ws/01001000/json/", apiErr.URL)
+	})
+
+	t.Run("erro payload", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			payload string
+		}{
+			{"boolean", `{"erro": true}`},
+			{"string", `{"erro": "true"}`},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				var hits atomic.Int32
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					hits.Add(1)
+					w.Header().Set("Content-Type", "application/json")
+					_, _ = w.Write([]byte(tc.payload))
+				}))
+				defer srv.Close()
+
+				c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNegativeCacheTTL(0))
+
+				address, err := c.Cep(context.Background(), "99999999")
+				assert.Nil(t, address)
+				assert.ErrorIs(t, err, ErrCepNotFound)
+				assert.EqualError(t, err, "cep not found: 99999999")
+
+				var cached cepEntry
+				found := c.cache.Get(context.Background(), cacheKey("99999999"), &cached)
+				assert.False(t, found)
+
+				_, err = c.Cep(context.Background(), "99999999")
+				assert.ErrorIs(t, err, ErrCepNotFound)
+				assert.Equal(t, int32(2), hits.Load())
+			})
+		}
+	})
+
+	t.Run("concurrent lookups share one request", func(t *testing.T) {
+		var hits atomic.Int32
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hits.Add(1)
+			<-release
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"cep": "01001-000"}`))
+		}))
+		defer srv.Close()
+
+		client, mock := redismock.NewClientMock()
+		clock := newFakeClock()
+		key := cacheKey("01001000")
+
+		const callers = 20
+		for range callers {
+			mock.ExpectGet(key).RedisNil()
+		}
+		entry := cepEntry{Version: CacheSchemaVersion, Address: Address{Cep: "01001-000"}, StoredAt: clock.Now()}
+		mock.ExpectSet(key, encodeGob(t, entry), cacheTTL).SetVal("OK")
+		mock.MatchExpectationsInOrder(false)
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithCache(NewRedisCache(client)), WithClock(clock))
+
+		var wg sync.WaitGroup
+		for range callers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				address, err := c.Cep(context.Background(), "01001-000")
+				assert.NoError(t, err)
+				assert.Equal(t, &Address{Cep: "01001-000"}, address)
+			}()
+		}
+
+		assert.Eventually(t, func() bool {
+			return c.cepFlight.waiters(key) == callers
+		}, time.Second, time.Millisecond)
+
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), hits.Load())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("canceled caller does not cancel shared request", func(t *testing.T) {
+		var hits atomic.Int32
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hits.Add(1)
+			<-release
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"cep": "01001-000"}`))
+		}))
+		defer srv.Close()
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		canceled := make(chan error)
+		go func() {
+			_, err := c.Cep(ctx, "01001000")
+			canceled <- err
+		}()
+
+		done := make(chan *Address)
+		go func() {
+			address, err := c.Cep(context.Background(), "01001000")
+			assert.NoError(t, err)
+			done <- address
+		}()
+
+		key := cacheKey("01001000")
+		assert.Eventually(t, func() bool {
+			return c.cepFlight.waiters(key) == 2
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-canceled, context.Canceled)
+
+		close(release)
+		assert.Equal(t, &Address{Cep: "01001-000"}, <-done)
+		assert.Equal(t, int32(1), hits.Load())
+	})
+
+	t.Run("negative cache", func(t *testing.T) {
+		var hits atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"erro": true}`))
+		}))
+		defer srv.Close()
+
+		clock := newFakeClock()
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithClock(clock), WithNegativeCacheTTL(time.Minute))
+		defer c.Close()
+
+		for range 3 {
+			address, err := c.Cep(context.Background(), "99999-999")
+			assert.Nil(t, address)
+			assert.ErrorIs(t, err, ErrCepNotFound)
+			assert.EqualError(t, err, "cep not found: 99999999")
+		}
+
+		assert.Equal(t, int32(1), hits.Load())
+
+		var cached cepEntry
+		found := c.cache.Get(context.Background(), cacheKey("99999999"), &cached)
+		assert.True(t, found)
+		assert.Equal(t, cepEntry{Version: CacheSchemaVersion, NotFound: true, StoredAt: clock.Now()}, cached)
+
+		clock.Advance(time.Minute)
+
+		_, err := c.Cep(context.Background(), "99999999")
+		assert.ErrorIs(t, err, ErrCepNotFound)
+		assert.Equal(t, int32(2), hits.Load())
+	})
+
+	t.Run("negative cache with redis", func(t *testing.T) {
+		var hits atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"erro": true}`))
+		}))
+		defer srv.Close()
+
+		client, mock := redismock.NewClientMock()
+		clock := newFakeClock()
+
+		key := cacheKey("99999999")
+		tombstone := encodeGob(t, cepEntry{Version: CacheSchemaVersion, NotFound: true, StoredAt: clock.Now()})
+		mock.ExpectGet(key).RedisNil()
+		mock.ExpectSet(key, tombstone, negativeCacheTTL).SetVal("OK")
+		mock.ExpectGet(key).SetVal(string(tombstone))
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithCache(NewRedisCache(client)), WithClock(clock))
+
+		for range 2 {
+			address, err := c.Cep(context.Background(), "99999999")
+			assert.Nil(t, address)
+			assert.ErrorIs(t, err, ErrCepNotFound)
+		}
+
+		assert.Equal(t, int32(1), hits.Load())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("integration", func(t *testing.T) {
 		if testing.Short() {
 			t.Log("integration testing skipped")
 			t.Skip()
 		}
 
-		c := New(NewHTTPClient(1))
+		c := New()
 		address, err := c.Cep(context.Background(), "01001000")
 		assert.NoError(t, err)
 
@@ -38,15 +310,351 @@ func TestViaCep_Client_Cep(t *testing.T) {
 	})
 }
 
+// versionedServer answers CEP lookups with an increasing Logradouro ("v1", "v2", ...), or
+// with HTTP 503 while failing is set.
+type versionedServer struct {
+	*httptest.Server
+	hits    atomic.Int32
+	failing atomic.Bool
+}
+
+func newVersionedServer() *versionedServer {
+	s := &versionedServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hit := s.hits.Add(1)
+		if s.failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"cep": "01001-000", "logradouro": "v%d"}`, hit)
+	}))
+
+	return s
+}
+
+func TestViaCep_Client_CepWithInfo(t *testing.T) {
+	t.Run("fresh and cached lookups", func(t *testing.T) {
+		srv := newVersionedServer()
+		defer srv.Close()
+
+		clock := newFakeClock()
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithClock(clock))
+		defer c.Close()
+
+		address, info, err := c.CepWithInfo(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", address.Logradouro)
+		assert.Equal(t, LookupInfo{StoredAt: clock.Now()}, info)
+
+		storedAt := clock.Now()
+		clock.Advance(time.Minute)
+
+		address, info, err = c.CepWithInfo(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", address.Logradouro)
+		assert.Equal(t, LookupInfo{Cached: true, StoredAt: storedAt}, info)
+	})
+
+	t.Run("stale while revalidate", func(t *testing.T) {
+		srv := newVersionedServer()
+		defer srv.Close()
+
+		clock := newFakeClock()
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithClock(clock), WithStaleWhileRevalidate(time.Hour))
+		defer c.Close()
+
+		_, err := c.Cep(context.Background(), "01001000")
+		assert.NoError(t, err)
+
+		storedAt := clock.Now()
+		clock.Advance(cacheTTL + time.Minute)
+
+		address, info, err := c.CepWithInfo(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", address.Logradouro)
+		assert.Equal(t, LookupInfo{Cached: true, Stale: true, Revalidating: true, StoredAt: storedAt}, info)
+
+		assert.Eventually(t, func() bool {
+			var entry cepEntry
+			return c.cache.Get(context.Background(), cacheKey("01001000"), &entry) && entry.Address.Logradouro == "v2"
+		}, time.Second, time.Millisecond)
+
+		address, info, err = c.CepWithInfo(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", address.Logradouro)
+		assert.Equal(t, LookupInfo{Cached: true, StoredAt: clock.Now()}, info)
+		assert.Equal(t, int32(2), srv.hits.Load())
+	})
+
+	t.Run("stale while revalidate keeps stale entry on failure", func(t *testing.T) {
+		srv := newVersionedServer()
+		defer srv.Close()
+
+		clock := newFakeClock()
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithClock(clock), WithStaleWhileRevalidate(time.Hour))
+		defer c.Close()
+
+		_, err := c.Cep(context.Background(), "01001000")
+		assert.NoError(t, err)
+
+		srv.failing.Store(true)
+		clock.Advance(cacheTTL + time.Minute)
+
+		_, info, err := c.CepWithInfo(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.True(t, info.Revalidating)
+
+		assert.Eventually(t, func() bool {
+			return srv.hits.Load() == 2 && c.cepFlight.waiters(cacheKey("01001000")) == 0
+		}, time.Second, time.Millisecond)
+
+		address, info, err := c.CepWithInfo(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", address.Logradouro)
+		assert.True(t, info.Stale)
+	})
+
+	t.Run("stale if error", func(t *testing.T) {
+		srv := newVersionedServer()
+		defer srv.Close()
+
+		clock := newFakeClock()
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithClock(clock), WithStaleIfError(time.Hour))
+		defer c.Close()
+
+		_, err := c.Cep(context.Background(), "01001000")
+		assert.NoError(t, err)
+
+		storedAt := clock.Now()
+		srv.failing.Store(true)
+		clock.Advance(cacheTTL + time.Minute)
+
+		address, info, err := c.CepWithInfo(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", address.Logradouro)
+		assert.Equal(t, LookupInfo{Cached: true, Stale: true, StoredAt: storedAt}, info)
+		assert.Equal(t, int32(2), srv.hits.Load())
+
+		srv.failing.Store(false)
+
+		address, info, err = c.CepWithInfo(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, "v3", address.Logradouro)
+		assert.Equal(t, LookupInfo{StoredAt: clock.Now()}, info)
+	})
+
+	t.Run("stale if error does not hide canceled context", func(t *testing.T) {
+		srv := newVersionedServer()
+		defer srv.Close()
+
+		clock := newFakeClock()
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithClock(clock), WithStaleIfError(time.Hour))
+		defer c.Close()
+
+		_, err := c.Cep(context.Background(), "01001000")
+		assert.NoError(t, err)
+
+		clock.Advance(cacheTTL + time.Minute)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		address, _, err := c.CepWithInfo(ctx, "01001000")
+		assert.Nil(t, address)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("entry past stale window is refetched", func(t *testing.T) {
+		srv := newVersionedServer()
+		defer srv.Close()
+
+		clock := newFakeClock()
+		cache := NewMemoryCache(WithJanitorInterval(0))
+		defer cache.Close()
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithClock(clock), WithCache(cache), WithStaleIfError(time.Hour))
+
+		_, err := c.Cep(context.Background(), "01001000")
+		assert.NoError(t, err)
+
+		clock.Advance(cacheTTL + 2*time.Hour)
+
+		address, info, err := c.CepWithInfo(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", address.Logradouro)
+		assert.False(t, info.Stale)
+	})
+
+	t.Run("entry without timestamp is fresh", func(t *testing.T) {
+		srv := newVersionedServer()
+		defer srv.Close()
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithStaleIfError(time.Hour))
+		defer c.Close()
+
+		entry := cepEntry{Version: CacheSchemaVersion, Address: Address{Cep: "01001-000"}}
+		err := c.cache.Set(context.Background(), cacheKey("01001000"), entry, 0)
+		assert.NoError(t, err)
+
+		address, info, err := c.CepWithInfo(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, &Address{Cep: "01001-000"}, address)
+		assert.Equal(t, LookupInfo{Cached: true}, info)
+		assert.Equal(t, int32(0), srv.hits.Load())
+	})
+
+	t.Run("entry of another schema version is refetched", func(t *testing.T) {
+		srv := newVersionedServer()
+		defer srv.Close()
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		legacy := cepEntry{Address: Address{Cep: "01001-000", Logradouro: "legacy"}}
+		assert.NoError(t, c.cache.Set(context.Background(), cacheKey("01001000"), legacy, 0))
+
+		address, info, err := c.CepWithInfo(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", address.Logradouro)
+		assert.False(t, info.Cached)
+
+		var entry cepEntry
+		assert.True(t, c.cache.Get(context.Background(), cacheKey("01001000"), &entry))
+		assert.Equal(t, CacheSchemaVersion, entry.Version)
+		assert.Equal(t, CacheStats{Misses: 1, Sets: 1, Entries: 1, Bytes: c.Stats().Bytes}, c.Stats())
+	})
+
+	t.Run("invalid cep", func(t *testing.T) {
+		c := New(WithNoCache())
+
+		address, info, err := c.CepWithInfo(context.Background(), "invalid")
+		assert.Nil(t, address)
+		assert.Equal(t, LookupInfo{}, info)
+		assert.ErrorIs(t, err, ErrInvalidCEP)
+	})
+}
+
 func TestViaCep_Client_Addresses(t *testing.T) {
+	t.Run("escaped search path", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/ws/MG/S%C3%A3o%20Jo%C3%A3o%20del-Rei/Rua%207%2F8/json/", r.URL.EscapedPath())
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[{"cep": "36300-000", "logradouro": "Rua 7/8", "uf": "MG"}]`))
+		}))
+		defer srv.Close()
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+
+		addresses, err := c.Addresses(context.Background(), "mg", "São João del-Rei", "Rua 7/8")
+		assert.NoError(t, err)
+		assert.Equal(t, []Address{{Cep: "36300-000", Logradouro: "Rua 7/8", Uf: "MG"}}, addresses)
+	})
+
+	t.Run("cached in a versioned envelope", func(t *testing.T) {
+		var hits atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[{"cep": "91790-072"}]`))
+		}))
+		defer srv.Close()
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		key := cacheKey("RS", "Porto Alegre", "Domingos José")
+		assert.NoError(t, c.cache.Set(context.Background(), key, []Address{{Cep: "legacy"}}, 0))
+
+		for range 2 {
+			addresses, err := c.Addresses(context.Background(), "RS", "Porto Alegre", "Domingos José")
+			assert.NoError(t, err)
+			assert.Equal(t, []Address{{Cep: "91790-072"}}, addresses)
+		}
+
+		assert.Equal(t, int32(1), hits.Load())
+
+		var entry addressesEntry
+		assert.True(t, c.cache.Get(context.Background(), key, &entry))
+		assert.Equal(t, addressesEntry{Version: CacheSchemaVersion, Addresses: []Address{{Cep: "91790-072"}}}, entry)
+	})
+
+	t.Run("concurrent searches share one request", func(t *testing.T) {
+		var hits atomic.Int32
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hits.Add(1)
+			<-release
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[{"cep": "91790-072"}, {"cep": "91910-420"}]`))
+		}))
+		defer srv.Close()
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		const callers = 20
+		results := make(chan []Address, callers)
+
+		var wg sync.WaitGroup
+		for range callers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				addresses, err := c.Addresses(context.Background(), "RS", "Porto Alegre", "Domingos José")
+				assert.NoError(t, err)
+				results <- addresses
+			}()
+		}
+
+		key := cacheKey("RS", "Porto Alegre", "Domingos José")
+		assert.Eventually(t, func() bool {
+			return c.addressesFlight.waiters(key) == callers
+		}, time.Second, time.Millisecond)
+
+		close(release)
+		wg.Wait()
+		close(results)
+
+		assert.Equal(t, int32(1), hits.Load())
+		for addresses := range results {
+			assert.Equal(t, []Address{{Cep: "91790-072"}, {Cep: "91910-420"}}, addresses)
+			addresses[0].Cep = "changed by caller"
+		}
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		var hits atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			hits.Add(1)
+		}))
+		defer srv.Close()
+
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+
+		addresses, err := c.Addresses(context.Background(), "XX", "Porto Alegre", "Domingos")
+		assert.Nil(t, addresses)
+		assert.EqualError(t, err, `invalid address search: uf "XX" is not a valid federative unit`)
+
+		addresses, err = c.Addresses(context.Background(), "RS", "Porto Alegre", "Do")
+		assert.Nil(t, addresses)
+		assert.ErrorIs(t, err, ErrInvalidSearch)
+
+		assert.Equal(t, int32(0), hits.Load())
+	})
+
 	t.Run("integration", func(t *testing.T) {
 		if testing.Short() {
 			t.Log("integration testing skipped")
 			t.Skip()
 		}
 
-		c := New(NewHTTPClient(1))
-		addresses, err := c.Addresses(context.Background(), "RS", "Porto Alegre", "Domingos+José")
+		c := New()
+		addresses, err := c.Addresses(context.Background(), "RS", "Porto Alegre", "Domingos José")
 		assert.NoError(t, err)
 
 		expected := []Address{
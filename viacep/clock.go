@@ -0,0 +1,16 @@
+package viacep
+
+import "time"
+
+// Clock abstracts the current time so that expiry and other time-based decisions can be
+// controlled in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,305 @@
+package viacep
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const addressCodecVersion = 3
+
+const (
+	addressCodecKindAddress byte = iota + 1
+	addressCodecKindAddresses
+	addressCodecKindCepEntry
+	addressCodecKindAddressesEntry
+)
+
+var errAddressCodecCorrupt = errors.New("address codec: corrupt data")
+
+// Codec serializes the values stored by a Cache.
+type Codec interface {
+	// Marshal encodes value into bytes.
+	//
+	// Parameters:
+	//   - value: The value to encode.
+	//
+	// Returns:
+	//   - []byte: The encoded value.
+	//   - error: If the value cannot be encoded, it will be returned. Otherwise, nil is returned.
+	Marshal(value any) ([]byte, error)
+
+	// Unmarshal decodes data into dest.
+	//
+	// Parameters:
+	//   - data: The bytes produced by Marshal.
+	//   - dest: A pointer to the variable where the decoded value will be stored.
+	//
+	// Returns:
+	//   - error: If the data cannot be decoded into dest, it will be returned. Otherwise, nil is returned.
+	Unmarshal(data []byte, dest any) error
+}
+
+// GobCodec encodes values with encoding/gob. It is the default codec of every Cache.
+type GobCodec struct{}
+
+// JSONCodec encodes values with encoding/json, which keeps cached values readable by
+// services written in other languages.
+type JSONCodec struct{}
+
+// AddressCodec is a compact binary codec for Address, []Address and the entries cached by
+// ViaCep, including their schema version. Strings are stored as length-prefixed UTF-8, without field names or type
+// descriptors. Any other type is rejected.
+type AddressCodec struct{}
+
+func (GobCodec) Marshal(value any) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(value); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, dest any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(dest)
+}
+
+func (JSONCodec) Marshal(value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec) Unmarshal(data []byte, dest any) error {
+	return json.Unmarshal(data, dest)
+}
+
+func (AddressCodec) Marshal(value any) ([]byte, error) {
+	buf := []byte{addressCodecVersion}
+
+	switch v := value.(type) {
+	case Address:
+		buf = appendAddress(append(buf, addressCodecKindAddress), &v)
+	case *Address:
+		buf = appendAddress(append(buf, addressCodecKindAddress), v)
+	case []Address:
+		buf = appendAddresses(append(buf, addressCodecKindAddresses), v)
+	case *[]Address:
+		buf = appendAddresses(append(buf, addressCodecKindAddresses), *v)
+	case cepEntry:
+		buf = appendCepEntry(append(buf, addressCodecKindCepEntry), &v)
+	case *cepEntry:
+		buf = appendCepEntry(append(buf, addressCodecKindCepEntry), v)
+	case addressesEntry:
+		buf = appendAddressesEntry(append(buf, addressCodecKindAddressesEntry), &v)
+	case *addressesEntry:
+		buf = appendAddressesEntry(append(buf, addressCodecKindAddressesEntry), v)
+	default:
+		return nil, fmt.Errorf("address codec: unsupported type %T", value)
+	}
+
+	return buf, nil
+}
+
+func (AddressCodec) Unmarshal(data []byte, dest any) error {
+	if len(data) < 2 || data[0] != addressCodecVersion {
+		return errAddressCodecCorrupt
+	}
+
+	r := &addressReader{data: data[2:]}
+
+	switch d := dest.(type) {
+	case *Address:
+		if data[1] != addressCodecKindAddress {
+			return fmt.Errorf("address codec: cannot decode into %T", dest)
+		}
+		r.readAddress(d)
+	case *[]Address:
+		if data[1] != addressCodecKindAddresses {
+			return fmt.Errorf("address codec: cannot decode into %T", dest)
+		}
+		*d = r.readAddresses()
+	case *cepEntry:
+		if data[1] != addressCodecKindCepEntry {
+			return fmt.Errorf("address codec: cannot decode into %T", dest)
+		}
+		r.readCepEntry(d)
+	case *addressesEntry:
+		if data[1] != addressCodecKindAddressesEntry {
+			return fmt.Errorf("address codec: cannot decode into %T", dest)
+		}
+		r.readAddressesEntry(d)
+	default:
+		return fmt.Errorf("address codec: unsupported type %T", dest)
+	}
+
+	if r.err != nil || len(r.data) != 0 {
+		return errAddressCodecCorrupt
+	}
+
+	return nil
+}
+
+func addressFields(a *Address) [13]*string {
+	return [13]*string{
+		&a.Cep, &a.Logradouro, &a.Complemento, &a.Unidade, &a.Bairro, &a.Localidade, &a.Uf,
+		&a.Estado, &a.Regiao, &a.Ibge, &a.Gia, &a.Ddd, &a.Siafi,
+	}
+}
+
+func appendAddress(buf []byte, a *Address) []byte {
+	for _, field := range addressFields(a) {
+		buf = binary.AppendUvarint(buf, uint64(len(*field)))
+		buf = append(buf, *field...)
+	}
+
+	return buf
+}
+
+func appendAddresses(buf []byte, addresses []Address) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(addresses)))
+	for i := range addresses {
+		buf = appendAddress(buf, &addresses[i])
+	}
+
+	return buf
+}
+
+func appendCepEntry(buf []byte, e *cepEntry) []byte {
+	var storedAt int64
+	if !e.StoredAt.IsZero() {
+		storedAt = e.StoredAt.UnixNano()
+	}
+
+	var notFound byte
+	if e.NotFound {
+		notFound = 1
+	}
+
+	buf = binary.AppendVarint(buf, int64(e.Version))
+	buf = append(buf, notFound)
+	buf = binary.AppendVarint(buf, storedAt)
+	buf = binary.AppendVarint(buf, int64(e.FreshFor))
+	return appendAddress(buf, &e.Address)
+}
+
+func appendAddressesEntry(buf []byte, e *addressesEntry) []byte {
+	buf = binary.AppendVarint(buf, int64(e.Version))
+	return appendAddresses(buf, e.Addresses)
+}
+
+// addressReader decodes the AddressCodec format. The first error is kept in err and turns
+// every later read into a no-op.
+type addressReader struct {
+	data []byte
+	err  error
+}
+
+func (r *addressReader) readUvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errAddressCodecCorrupt
+		return 0
+	}
+
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *addressReader) readVarint() int64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = errAddressCodecCorrupt
+		return 0
+	}
+
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *addressReader) readByte() byte {
+	if r.err != nil {
+		return 0
+	}
+
+	if len(r.data) == 0 {
+		r.err = errAddressCodecCorrupt
+		return 0
+	}
+
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *addressReader) readString() string {
+	n := r.readUvarint()
+	if r.err != nil {
+		return ""
+	}
+
+	if n > uint64(len(r.data)) {
+		r.err = errAddressCodecCorrupt
+		return ""
+	}
+
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+func (r *addressReader) readAddress(a *Address) {
+	for _, field := range addressFields(a) {
+		*field = r.readString()
+	}
+}
+
+func (r *addressReader) readAddresses() []Address {
+	n := r.readUvarint()
+	if r.err != nil {
+		return nil
+	}
+
+	// Every address takes at least one byte per field, which bounds the allocation below.
+	if n > uint64(len(r.data)/len(addressFields(&Address{}))) {
+		r.err = errAddressCodecCorrupt
+		return nil
+	}
+
+	addresses := make([]Address, n)
+	for i := range addresses {
+		r.readAddress(&addresses[i])
+	}
+
+	return addresses
+}
+
+func (r *addressReader) readCepEntry(e *cepEntry) {
+	e.Version = int(r.readVarint())
+	e.NotFound = r.readByte() == 1
+
+	e.StoredAt = time.Time{}
+	if storedAt := r.readVarint(); storedAt != 0 {
+		e.StoredAt = time.Unix(0, storedAt).UTC()
+	}
+
+	e.FreshFor = time.Duration(r.readVarint())
+
+	r.readAddress(&e.Address)
+}
+
+func (r *addressReader) readAddressesEntry(e *addressesEntry) {
+	e.Version = int(r.readVarint())
+	e.Addresses = r.readAddresses()
+}
@@ -0,0 +1,317 @@
+package viacep
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+var codecs = map[string]Codec{
+	"gob":     GobCodec{},
+	"json":    JSONCodec{},
+	"address": AddressCodec{},
+}
+
+func codecFixtures() (Address, []Address, cepEntry) {
+	address := Address{
+		Cep:         "01001-000",
+		Logradouro:  "Praça da Sé",
+		Complemento: "lado ímpar",
+		Bairro:      "Sé",
+		Localidade:  "São Paulo",
+		Uf:          "SP",
+		Estado:      "São Paulo",
+		Regiao:      "Sudeste",
+		Ibge:        "3550308",
+		Gia:         "1004",
+		Ddd:         "11",
+		Siafi:       "7107",
+	}
+
+	addresses := []Address{address, {Cep: "91790-072", Logradouro: "Rua Domingos José Poli", Uf: "RS"}}
+	entry := cepEntry{Version: CacheSchemaVersion, Address: address, StoredAt: time.Date(2024, time.November, 29, 10, 0, 0, 123, time.UTC)}
+
+	return address, addresses, entry
+}
+
+func TestViaCep_Codec_RoundTrip(t *testing.T) {
+	address, addresses, entry := codecFixtures()
+	entry.FreshFor = 72 * time.Hour
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			data, err := codec.Marshal(address)
+			assert.NoError(t, err)
+
+			var decodedAddress Address
+			assert.NoError(t, codec.Unmarshal(data, &decodedAddress))
+			assert.Equal(t, address, decodedAddress)
+
+			data, err = codec.Marshal(&address)
+			assert.NoError(t, err)
+
+			decodedAddress = Address{}
+			assert.NoError(t, codec.Unmarshal(data, &decodedAddress))
+			assert.Equal(t, address, decodedAddress)
+
+			data, err = codec.Marshal(addresses)
+			assert.NoError(t, err)
+
+			var decodedAddresses []Address
+			assert.NoError(t, codec.Unmarshal(data, &decodedAddresses))
+			assert.Equal(t, addresses, decodedAddresses)
+
+			data, err = codec.Marshal(entry)
+			assert.NoError(t, err)
+
+			var decodedEntry cepEntry
+			assert.NoError(t, codec.Unmarshal(data, &decodedEntry))
+			assert.Equal(t, entry, decodedEntry)
+
+			envelope := addressesEntry{Version: CacheSchemaVersion, Addresses: addresses}
+			data, err = codec.Marshal(envelope)
+			assert.NoError(t, err)
+
+			var decodedEnvelope addressesEntry
+			assert.NoError(t, codec.Unmarshal(data, &decodedEnvelope))
+			assert.Equal(t, envelope, decodedEnvelope)
+
+			tombstone := cepEntry{NotFound: true}
+			data, err = codec.Marshal(&tombstone)
+			assert.NoError(t, err)
+
+			decodedEntry = cepEntry{}
+			assert.NoError(t, codec.Unmarshal(data, &decodedEntry))
+			assert.Equal(t, tombstone, decodedEntry)
+		})
+	}
+}
+
+func TestViaCep_Codec_JSONCodec(t *testing.T) {
+	_, _, entry := codecFixtures()
+	entry.Address = Address{Cep: "01001-000"}
+
+	data, err := JSONCodec{}.Marshal(entry)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"version": 1,
+		"address": {"cep": "01001-000", "logradouro": "", "complemento": "", "unidade": "", "bairro": "", "localidade": "",
+			"uf": "", "estado": "", "regiao": "", "ibge": "", "gia": "", "ddd": "", "siafi": ""},
+		"storedAt": "2024-11-29T10:00:00.000000123Z"
+	}`, string(data))
+}
+
+func TestViaCep_Codec_AddressCodec(t *testing.T) {
+	address, addresses, _ := codecFixtures()
+	codec := AddressCodec{}
+
+	t.Run("compact encoding", func(t *testing.T) {
+		binaryData, err := codec.Marshal(address)
+		assert.NoError(t, err)
+
+		gobData, err := GobCodec{}.Marshal(address)
+		assert.NoError(t, err)
+
+		jsonData, err := JSONCodec{}.Marshal(address)
+		assert.NoError(t, err)
+
+		assert.Less(t, len(binaryData), len(jsonData))
+		assert.Less(t, len(binaryData), len(gobData))
+	})
+
+	t.Run("unsupported types", func(t *testing.T) {
+		_, err := codec.Marshal("01001000")
+		assert.EqualError(t, err, "address codec: unsupported type string")
+
+		data, err := codec.Marshal(address)
+		assert.NoError(t, err)
+
+		var dest string
+		err = codec.Unmarshal(data, &dest)
+		assert.EqualError(t, err, "address codec: unsupported type *string")
+	})
+
+	t.Run("kind mismatch", func(t *testing.T) {
+		data, err := codec.Marshal(address)
+		assert.NoError(t, err)
+
+		var decodedAddresses []Address
+		err = codec.Unmarshal(data, &decodedAddresses)
+		assert.EqualError(t, err, "address codec: cannot decode into *[]viacep.Address")
+
+		var decodedEntry cepEntry
+		err = codec.Unmarshal(data, &decodedEntry)
+		assert.EqualError(t, err, "address codec: cannot decode into *viacep.cepEntry")
+
+		data, err = codec.Marshal(addresses)
+		assert.NoError(t, err)
+
+		var decodedAddress Address
+		err = codec.Unmarshal(data, &decodedAddress)
+		assert.EqualError(t, err, "address codec: cannot decode into *viacep.Address")
+	})
+
+	t.Run("corrupt data", func(t *testing.T) {
+		data, err := codec.Marshal(addresses)
+		assert.NoError(t, err)
+
+		testCases := [][]byte{
+			nil,
+			{addressCodecVersion},
+			{99, addressCodecKindAddress},
+			data[:len(data)-1],
+			append(append([]byte{}, data...), 0),
+			{addressCodecVersion, addressCodecKindAddresses, 0xff, 0xff, 0xff, 0xff, 0x0f},
+			{addressCodecVersion, addressCodecKindAddress, 0x80},
+			{addressCodecVersion, addressCodecKindCepEntry},
+			{addressCodecVersion, addressCodecKindCepEntry, 2, 0, 0x80},
+			{addressCodecVersion, addressCodecKindAddressesEntry, 2},
+		}
+
+		for _, tc := range testCases {
+			var dest []Address
+			var entry cepEntry
+			var single Address
+
+			var envelope addressesEntry
+
+			switch {
+			case len(tc) > 1 && tc[1] == addressCodecKindAddressesEntry:
+				assert.ErrorIs(t, codec.Unmarshal(tc, &envelope), errAddressCodecCorrupt, tc)
+			case len(tc) > 1 && tc[1] == addressCodecKindCepEntry:
+				assert.ErrorIs(t, codec.Unmarshal(tc, &entry), errAddressCodecCorrupt, tc)
+			case len(tc) > 1 && tc[1] == addressCodecKindAddress:
+				assert.ErrorIs(t, codec.Unmarshal(tc, &single), errAddressCodecCorrupt, tc)
+			default:
+				assert.ErrorIs(t, codec.Unmarshal(tc, &dest), errAddressCodecCorrupt, tc)
+			}
+		}
+	})
+}
+
+func TestViaCep_Codec_Caches(t *testing.T) {
+	address, _, _ := codecFixtures()
+
+	t.Run("memory cache", func(t *testing.T) {
+		for name, codec := range codecs {
+			cache := NewMemoryCache(WithMemoryCacheCodec(codec))
+
+			err := cache.Set(context.Background(), "address", address, 0)
+			assert.NoError(t, err, name)
+
+			var dest Address
+			found := cache.Get(context.Background(), "address", &dest)
+			assert.True(t, found, name)
+			assert.Equal(t, address, dest, name)
+
+			cache.Close()
+		}
+	})
+
+	t.Run("redis cache stores json", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		cache := NewRedisCache(client, WithRedisCacheCodec(JSONCodec{}))
+
+		data, err := JSONCodec{}.Marshal(address)
+		assert.NoError(t, err)
+
+		mock.ExpectSet("address", data, time.Minute).SetVal("OK")
+		mock.ExpectGet("address").SetVal(string(data))
+
+		err = cache.Set(context.Background(), "address", address, time.Minute)
+		assert.NoError(t, err)
+
+		var dest Address
+		found := cache.Get(context.Background(), "address", &dest)
+		assert.True(t, found)
+		assert.Equal(t, address, dest)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("encode error", func(t *testing.T) {
+		cache := NewMemoryCache(WithMemoryCacheCodec(AddressCodec{}))
+		defer cache.Close()
+
+		err := cache.Set(context.Background(), "user:1", 1, 0)
+		assert.EqualError(t, err, "failed to encode value of type int: address codec: unsupported type int")
+	})
+}
+
+func BenchmarkViaCep_Codec_Marshal(b *testing.B) {
+	_, _, entry := codecFixtures()
+
+	for name, codec := range codecs {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				_, _ = codec.Marshal(entry)
+			}
+		})
+	}
+}
+
+func BenchmarkViaCep_Codec_Unmarshal(b *testing.B) {
+	_, _, entry := codecFixtures()
+
+	for name, codec := range codecs {
+		data, err := codec.Marshal(entry)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				var dest cepEntry
+				_ = codec.Unmarshal(data, &dest)
+			}
+		})
+	}
+}
+
+func TestViaCep_Codec_ViaCep(t *testing.T) {
+	ctx := context.Background()
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				if strings.Count(r.URL.Path, "/") > 4 {
+					_, _ = w.Write([]byte(`[{"cep": "91790-072"}]`))
+					return
+				}
+
+				_, _ = w.Write([]byte(`{"cep": "01001-000"}`))
+			}))
+			defer srv.Close()
+
+			c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithCache(NewMemoryCache(WithMemoryCacheCodec(codec))))
+			defer c.Close()
+
+			for range 3 {
+				address, err := c.Cep(ctx, "01001000")
+				assert.NoError(t, err)
+				assert.Equal(t, &Address{Cep: "01001-000"}, address)
+
+				addresses, err := c.Addresses(ctx, "RS", "Porto Alegre", "Domingos")
+				assert.NoError(t, err)
+				assert.Equal(t, []Address{{Cep: "91790-072"}}, addresses)
+			}
+
+			assert.Equal(t, int32(2), hits.Load())
+			stats := c.Stats()
+			assert.Equal(t, uint64(4), stats.Hits)
+			assert.Equal(t, uint64(2), stats.Sets)
+			assert.Zero(t, stats.SetErrors)
+		})
+	}
+}
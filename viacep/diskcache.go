@@ -0,0 +1,472 @@
+package viacep
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCompactionInterval = 10 * time.Minute
+	diskEntryHeaderSize       = 8
+	diskTempPrefix            = ".tmp-"
+	diskDirPerm               = 0o750
+)
+
+// DiskCache is a Cache persisted in a directory, for hosts without Redis that must keep their
+// cache across restarts. Each entry is a file named after the SHA-256 of its key, sharded into
+// 256 subdirectories, holding the expiry time followed by the encoded value.
+//
+// Writes go to a temporary file that is synced and then renamed over the entry, so a crash
+// leaves either the old or the new value, never a partial one. Expired entries are ignored on
+// read and removed by compaction, which also evicts the least recently written entries while
+// the directory exceeds the size set with WithMaxDiskBytes. Compaction runs periodically in
+// the background until Close, and can be triggered with Compact. Compaction only touches files
+// laid out by the cache, so other files in the directory are left alone.
+//
+// A DiskCache is safe for concurrent use by multiple goroutines. Sharing a directory between
+// processes is not supported.
+type DiskCache struct {
+	mu                 sync.RWMutex
+	dir                string
+	maxBytes           int64
+	compactionInterval time.Duration
+	clock              Clock
+	codec              Codec
+	stop               chan struct{}
+	closeOnce          sync.Once
+	counters           cacheCounters
+}
+
+// DiskCacheOption configures a DiskCache created with NewDiskCache.
+type DiskCacheOption func(*DiskCache)
+
+type diskEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// WithMaxDiskBytes limits the total size of the entry files kept by compaction. A value <= 0
+// removes the limit.
+func WithMaxDiskBytes(maxBytes int64) DiskCacheOption {
+	return func(c *DiskCache) {
+		c.maxBytes = maxBytes
+	}
+}
+
+// WithCompactionInterval sets how often the cache is compacted in the background. A value <= 0
+// disables background compaction, leaving it to explicit calls to Compact.
+func WithCompactionInterval(interval time.Duration) DiskCacheOption {
+	return func(c *DiskCache) {
+		c.compactionInterval = interval
+	}
+}
+
+// WithDiskCacheCodec sets the codec used to serialize values. Defaults to GobCodec.
+func WithDiskCacheCodec(codec Codec) DiskCacheOption {
+	return func(c *DiskCache) {
+		c.codec = codec
+	}
+}
+
+// WithDiskCacheClock sets the clock used to compute and check expiry times.
+func WithDiskCacheClock(clock Clock) DiskCacheOption {
+	return func(c *DiskCache) {
+		c.clock = clock
+	}
+}
+
+// NewDiskCache creates a DiskCache storing its entries in dir, which is created if needed.
+// Entries written by a previous DiskCache on the same directory are served again. By default
+// the size is not limited and the cache is compacted every ten minutes.
+func NewDiskCache(dir string, opts ...DiskCacheOption) (*DiskCache, error) {
+	c := &DiskCache{
+		dir:                dir,
+		compactionInterval: defaultCompactionInterval,
+		clock:              systemClock{},
+		codec:              GobCodec{},
+		stop:               make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if err := os.MkdirAll(dir, diskDirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	if c.compactionInterval > 0 {
+		go c.compactor()
+	}
+
+	return c, nil
+}
+
+func (c *DiskCache) Get(_ context.Context, key string, dest any) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data, err := os.ReadFile(filepath.Clean(c.path(key)))
+	if err != nil || len(data) < diskEntryHeaderSize || c.expired(data, c.clock.Now()) {
+		return c.counters.read(false)
+	}
+
+	if err := c.codec.Unmarshal(data[diskEntryHeaderSize:], dest); err != nil {
+		c.counters.decodeFailure()
+		return false
+	}
+
+	return c.counters.read(true)
+}
+
+func (c *DiskCache) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	serialized, err := c.codec.Marshal(value)
+	if err != nil {
+		c.counters.setErrors.Add(1)
+		return fmt.Errorf("failed to encode value of type %T: %w", value, err)
+	}
+
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = c.clock.Now().Add(ttl).UnixNano()
+	}
+
+	data, err := binary.Append(make([]byte, 0, diskEntryHeaderSize+len(serialized)), binary.BigEndian, expiresAt)
+	if err != nil {
+		c.counters.setErrors.Add(1)
+		return fmt.Errorf("failed to encode value of type %T: %w", value, err)
+	}
+
+	data = append(data, serialized...)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = writeFileAtomic(c.path(key), data)
+	c.counters.write(err)
+	if err != nil {
+		return fmt.Errorf("failed to write value to cache: %w", err)
+	}
+
+	return nil
+}
+
+func (c *DiskCache) Delete(_ context.Context, key string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := os.Remove(c.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete key from cache: %w", err)
+	}
+
+	return nil
+}
+
+// Compact removes expired entries and temporary files left by interrupted writes, then removes
+// the least recently written entries until the cache fits in the size set with WithMaxDiskBytes.
+// The directory is scanned while reads and writes go on; they only wait while the files found
+// are removed, and entries written in the meantime are kept.
+func (c *DiskCache) Compact() error {
+	c.mu.RLock()
+	plan, err := c.scan()
+	c.mu.RUnlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to compact cache: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.removeStale(plan); err != nil {
+		return fmt.Errorf("failed to compact cache: %w", err)
+	}
+
+	if err := c.evictOversize(plan); err != nil {
+		return fmt.Errorf("failed to compact cache: %w", err)
+	}
+
+	return nil
+}
+
+// Stats returns a snapshot of the activity of the cache. Evictions counts the entries removed by
+// compaction. Entries and Bytes are measured by scanning the directory, including expired
+// entries not yet compacted, so the cost of Stats grows with the number of entries.
+func (c *DiskCache) Stats() CacheStats {
+	stats := c.counters.snapshot()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_ = c.walkFiles(func(_ string, d fs.DirEntry, temp bool) error {
+		if temp {
+			return nil
+		}
+
+		if info, err := d.Info(); err == nil {
+			stats.Entries++
+			stats.Bytes += info.Size()
+		}
+
+		return nil
+	})
+
+	return stats
+}
+
+// Close stops the background compaction. The cache remains usable afterwards.
+func (c *DiskCache) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+
+	return nil
+}
+
+// diskCompaction lists the files found by a scan of the cache: temporary files, expired entries
+// and the remaining entries with their total size.
+type diskCompaction struct {
+	temps   []string
+	expired []string
+	entries []diskEntry
+	size    int64
+}
+
+// scan lists the files compaction may remove. It only reads the directory, so it can run under
+// the read lock while writes go on.
+func (c *DiskCache) scan() (diskCompaction, error) {
+	var plan diskCompaction
+	now := c.clock.Now()
+
+	err := c.walkFiles(func(path string, d fs.DirEntry, temp bool) error {
+		switch {
+		case temp:
+			plan.temps = append(plan.temps, path)
+		case c.expiredFile(path, now):
+			plan.expired = append(plan.expired, path)
+		default:
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			plan.entries = append(plan.entries, diskEntry{path: path, size: info.Size(), modTime: info.ModTime()})
+			plan.size += info.Size()
+		}
+
+		return nil
+	})
+
+	return plan, err
+}
+
+// removeStale removes the temporary files and expired entries found by a scan. It must be called
+// with the write lock held: writes in progress during the scan have then completed, so the
+// temporary files left are leftovers, and expired entries are checked again in case they were
+// rewritten since.
+func (c *DiskCache) removeStale(plan diskCompaction) error {
+	for _, path := range plan.temps {
+		if err := removeFile(path); err != nil {
+			return err
+		}
+	}
+
+	now := c.clock.Now()
+	for _, path := range plan.expired {
+		if !c.expiredFile(path, now) {
+			continue
+		}
+
+		if err := removeFile(path); err != nil {
+			return err
+		}
+
+		c.counters.evictions.Add(1)
+	}
+
+	return nil
+}
+
+// evictOversize removes the least recently written entries found by a scan until the cache fits
+// in maxBytes. Entries rewritten since the scan are kept. It must be called with the write lock
+// held.
+func (c *DiskCache) evictOversize(plan diskCompaction) error {
+	if c.maxBytes <= 0 || plan.size <= c.maxBytes {
+		return nil
+	}
+
+	slices.SortFunc(plan.entries, func(a, b diskEntry) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	size := plan.size
+	for _, entry := range plan.entries {
+		if size <= c.maxBytes {
+			break
+		}
+
+		info, err := os.Stat(entry.path)
+		if err != nil || !info.ModTime().Equal(entry.modTime) {
+			size -= entry.size
+			if err == nil {
+				size += info.Size()
+			}
+
+			continue
+		}
+
+		if err := removeFile(entry.path); err != nil {
+			return err
+		}
+
+		c.counters.evictions.Add(1)
+		size -= entry.size
+	}
+
+	return nil
+}
+
+// removeFile removes path, ignoring files already gone.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// walkFiles calls fn for each file of the cache layout: the entries, named after the hash of
+// their key in the shard of its first two digits, and the temporary files in the shards. Any
+// other file below dir is left alone, as dir may be shared with other programs.
+func (c *DiskCache) walkFiles(fn func(path string, d fs.DirEntry, temp bool) error) error {
+	shards, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, shard := range shards {
+		if !shard.IsDir() || len(shard.Name()) != 2 || !isLowerHex(shard.Name()) {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(c.dir, shard.Name()))
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			temp := strings.HasPrefix(file.Name(), diskTempPrefix)
+			if !file.Type().IsRegular() || !temp && !isDiskEntryName(shard.Name(), file.Name()) {
+				continue
+			}
+
+			if err := fn(filepath.Join(c.dir, shard.Name(), file.Name()), file, temp); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *DiskCache) compactor() {
+	ticker := time.NewTicker(c.compactionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = c.Compact()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// path returns the file of key: <dir>/<first two hex digits of the hash>/<hash>.
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+// isDiskEntryName reports whether name is the file name of an entry in shard.
+func isDiskEntryName(shard, name string) bool {
+	return len(name) == sha256.Size*2 && strings.HasPrefix(name, shard) && isLowerHex(name)
+}
+
+func isLowerHex(s string) bool {
+	return strings.Trim(s, "0123456789abcdef") == ""
+}
+
+func (c *DiskCache) expired(data []byte, now time.Time) bool {
+	var expiresAt int64
+	if _, err := binary.Decode(data, binary.BigEndian, &expiresAt); err != nil {
+		return true
+	}
+
+	return expiresAt != 0 && now.UnixNano() >= expiresAt
+}
+
+// expiredFile reports whether the entry at path is expired. Files too short to hold a header
+// are reported as expired so that compaction removes them.
+func (c *DiskCache) expiredFile(path string, now time.Time) bool {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	header := make([]byte, diskEntryHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return true
+	}
+
+	return c.expired(header, now)
+}
+
+// writeFileAtomic replaces path with data by writing and syncing a temporary file in the same
+// directory and renaming it over path.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, diskDirPerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, diskTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,370 @@
+package viacep
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDiskCache(t *testing.T, dir string, opts ...DiskCacheOption) *DiskCache {
+	t.Helper()
+
+	cache, err := NewDiskCache(dir, append([]DiskCacheOption{WithCompactionInterval(0)}, opts...)...)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	return cache
+}
+
+// diskFiles lists the files below dir, relative to it.
+func diskFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, rel)
+		}
+
+		return err
+	})
+	assert.NoError(t, err)
+
+	return files
+}
+
+func TestViaCep_DiskCache_NewDiskCache(t *testing.T) {
+	t.Run("creates the directory", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "nested", "cache")
+		newTestDiskCache(t, dir)
+		assert.DirExists(t, dir)
+	})
+
+	t.Run("invalid directory", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		assert.NoError(t, os.WriteFile(file, nil, 0o600))
+
+		cache, err := NewDiskCache(filepath.Join(file, "cache"))
+		assert.Nil(t, cache)
+		assert.ErrorContains(t, err, "failed to create cache directory")
+	})
+}
+
+func TestViaCep_DiskCache_Get(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cache := newTestDiskCache(t, dir)
+
+	address := Address{Cep: "01001-000", Logradouro: "Praça da Sé"}
+	assert.NoError(t, cache.Set(ctx, "cep:1", address, 0))
+
+	t.Run("retrieve value with success", func(t *testing.T) {
+		var dest Address
+		assert.True(t, cache.Get(ctx, "cep:1", &dest))
+		assert.Equal(t, address, dest)
+	})
+
+	t.Run("key not found", func(t *testing.T) {
+		var dest Address
+		assert.False(t, cache.Get(ctx, "cep:2", &dest))
+		assert.Equal(t, Address{}, dest)
+	})
+
+	t.Run("survives a restart", func(t *testing.T) {
+		var dest Address
+		assert.True(t, newTestDiskCache(t, dir).Get(ctx, "cep:1", &dest))
+		assert.Equal(t, address, dest)
+	})
+
+	t.Run("deserialization error", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(cache.path("cep:1"), []byte("\x00\x00\x00\x00\x00\x00\x00\x00garbage"), 0o600))
+
+		var dest Address
+		assert.False(t, cache.Get(ctx, "cep:1", &dest))
+	})
+
+	t.Run("truncated file", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(cache.path("cep:1"), []byte("\x00\x00"), 0o600))
+
+		var dest Address
+		assert.False(t, cache.Get(ctx, "cep:1", &dest))
+	})
+}
+
+func TestViaCep_DiskCache_Set(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sharded by key hash", func(t *testing.T) {
+		dir := t.TempDir()
+		cache := newTestDiskCache(t, dir)
+		assert.NoError(t, cache.Set(ctx, "single", "value", 0))
+
+		// sha256("single") = 947f1875...
+		name := "947f187506f7629c81c81879a2cb2256455038e4ac770091d897fa0a8b945e3b"
+		assert.Equal(t, []string{filepath.Join("94", name)}, diskFiles(t, dir))
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		dir := t.TempDir()
+		cache := newTestDiskCache(t, dir)
+		assert.NoError(t, cache.Set(ctx, "user:1", "alice", 0))
+		assert.NoError(t, cache.Set(ctx, "user:1", "alicia", 0))
+
+		var dest string
+		assert.True(t, cache.Get(ctx, "user:1", &dest))
+		assert.Equal(t, "alicia", dest)
+		assert.Len(t, diskFiles(t, dir), 1)
+	})
+
+	t.Run("TTL expiry", func(t *testing.T) {
+		clock := newFakeClock()
+		cache := newTestDiskCache(t, t.TempDir(), WithDiskCacheClock(clock))
+		assert.NoError(t, cache.Set(ctx, "user:1", "alice", time.Minute))
+
+		var dest string
+		assert.True(t, cache.Get(ctx, "user:1", &dest))
+
+		clock.Advance(time.Minute)
+		assert.False(t, cache.Get(ctx, "user:1", &dest))
+	})
+
+	t.Run("codec", func(t *testing.T) {
+		dir := t.TempDir()
+		cache := newTestDiskCache(t, dir, WithDiskCacheCodec(JSONCodec{}))
+		assert.NoError(t, cache.Set(ctx, "user:1", map[string]int{"id": 1}, 0))
+
+		data, err := os.ReadFile(cache.path("user:1"))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":1}`, string(data[diskEntryHeaderSize:]))
+	})
+
+	t.Run("serialization error", func(t *testing.T) {
+		dir := t.TempDir()
+		cache := newTestDiskCache(t, dir)
+
+		err := cache.Set(ctx, "invalid", make(chan int), 0)
+		assert.EqualError(t, err, "failed to encode value of type chan int: gob NewTypeObject can't handle type: chan int")
+		assert.Empty(t, diskFiles(t, dir))
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		dir := t.TempDir()
+		cache := newTestDiskCache(t, dir)
+
+		path := cache.path("user:1")
+		assert.NoError(t, os.WriteFile(filepath.Dir(path), nil, 0o600))
+
+		err := cache.Set(ctx, "user:1", "alice", 0)
+		assert.ErrorContains(t, err, "failed to write value to cache")
+	})
+}
+
+func TestViaCep_DiskCache_Delete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cache := newTestDiskCache(t, dir)
+
+	assert.NoError(t, cache.Set(ctx, "user:1", "alice", 0))
+	assert.NoError(t, cache.Delete(ctx, "user:1"))
+	assert.False(t, cache.Get(ctx, "user:1", new(string)))
+	assert.Empty(t, diskFiles(t, dir))
+
+	assert.NoError(t, cache.Delete(ctx, "user:nonexistent"))
+}
+
+func TestViaCep_DiskCache_Compact(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("removes expired entries and leftovers", func(t *testing.T) {
+		dir := t.TempDir()
+		clock := newFakeClock()
+		cache := newTestDiskCache(t, dir, WithDiskCacheClock(clock))
+
+		assert.NoError(t, cache.Set(ctx, "short", "value", time.Minute))
+		assert.NoError(t, cache.Set(ctx, "forever", "value", 0))
+		shard := filepath.Dir(cache.path("forever"))
+		assert.NoError(t, os.WriteFile(filepath.Join(shard, diskTempPrefix+"123"), []byte("partial"), 0o600))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(cache.path("truncated")), 0o750))
+		assert.NoError(t, os.WriteFile(cache.path("truncated"), []byte("\x00"), 0o600))
+
+		clock.Advance(time.Minute)
+		assert.NoError(t, cache.Compact())
+
+		rel, _ := filepath.Rel(dir, cache.path("forever"))
+		assert.Equal(t, []string{rel}, diskFiles(t, dir))
+	})
+
+	t.Run("leaves foreign files alone", func(t *testing.T) {
+		dir := t.TempDir()
+		cache := newTestDiskCache(t, dir, WithMaxDiskBytes(1))
+
+		assert.NoError(t, cache.Set(ctx, "key", "value", 0))
+		shard := filepath.Base(filepath.Dir(cache.path("key")))
+		foreign := []string{
+			"x",
+			filepath.Join(shard, "notes.txt"),
+			filepath.Join(shard, strings.Repeat("0", 64)),
+			filepath.Join("zz", strings.Repeat("a", 64)),
+			filepath.Join("other", "nested", "file"),
+		}
+		for _, name := range foreign {
+			path := filepath.Join(dir, name)
+			assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+			assert.NoError(t, os.WriteFile(path, []byte("\x00\x00"), 0o600))
+		}
+
+		assert.NoError(t, cache.Compact())
+
+		assert.ElementsMatch(t, foreign, diskFiles(t, dir))
+		assert.Equal(t, CacheStats{Sets: 1, Evictions: 1}, cache.Stats())
+	})
+
+	t.Run("evicts least recently written entries over the size limit", func(t *testing.T) {
+		dir := t.TempDir()
+		cache := newTestDiskCache(t, dir, WithDiskCacheCodec(JSONCodec{}))
+
+		// Each entry takes the 8 byte header plus 3 bytes of JSON.
+		now := time.Now()
+		for i := range 5 {
+			key := fmt.Sprintf("key:%d", i)
+			assert.NoError(t, cache.Set(ctx, key, (i+1)*100, 0))
+			modTime := now.Add(time.Duration(i) * time.Second)
+			assert.NoError(t, os.Chtimes(cache.path(key), modTime, modTime))
+		}
+
+		cache.maxBytes = 33
+		assert.NoError(t, cache.Compact())
+
+		for i := range 5 {
+			var dest int
+			found := cache.Get(ctx, fmt.Sprintf("key:%d", i), &dest)
+			assert.Equal(t, i >= 2, found, i)
+		}
+	})
+
+	t.Run("keeps entries rewritten during the scan", func(t *testing.T) {
+		clock := newFakeClock()
+		cache := newTestDiskCache(t, t.TempDir(), WithDiskCacheClock(clock), WithDiskCacheCodec(JSONCodec{}))
+
+		assert.NoError(t, cache.Set(ctx, "short", 1, time.Minute))
+		assert.NoError(t, cache.Set(ctx, "old", 2, 0))
+		past := time.Now().Add(-time.Hour)
+		assert.NoError(t, os.Chtimes(cache.path("old"), past, past))
+
+		clock.Advance(time.Minute)
+		plan, err := cache.scan()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{cache.path("short")}, plan.expired)
+
+		assert.NoError(t, cache.Set(ctx, "short", 3, time.Minute))
+		assert.NoError(t, cache.Set(ctx, "old", 4, 0))
+
+		cache.maxBytes = 1
+		assert.NoError(t, cache.removeStale(plan))
+		assert.NoError(t, cache.evictOversize(plan))
+
+		var dest int
+		assert.True(t, cache.Get(ctx, "short", &dest))
+		assert.True(t, cache.Get(ctx, "old", &dest))
+		assert.Zero(t, cache.Stats().Evictions)
+	})
+
+	t.Run("background compaction", func(t *testing.T) {
+		clock := newFakeClock()
+		dir := t.TempDir()
+		cache, err := NewDiskCache(dir, WithDiskCacheClock(clock), WithCompactionInterval(5*time.Millisecond))
+		assert.NoError(t, err)
+		defer cache.Close()
+
+		assert.NoError(t, cache.Set(ctx, "short", "value", time.Minute))
+		clock.Advance(time.Minute)
+
+		assert.Eventually(t, func() bool {
+			return len(diskFiles(t, dir)) == 0
+		}, time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestViaCep_DiskCache_Concurrency(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cache := newTestDiskCache(t, dir, WithMaxDiskBytes(1<<20))
+
+	var wg sync.WaitGroup
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range 50 {
+				key := fmt.Sprintf("key:%d", i%10)
+				assert.NoError(t, cache.Set(ctx, key, worker, 0))
+
+				var dest int
+				if cache.Get(ctx, key, &dest) {
+					assert.GreaterOrEqual(t, dest, 0)
+				}
+
+				if i%10 == 0 {
+					assert.NoError(t, cache.Compact())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, diskFiles(t, dir), 10)
+}
+
+func TestViaCep_DiskCache_Close(t *testing.T) {
+	cache, err := NewDiskCache(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Close())
+	assert.NoError(t, cache.Close())
+
+	assert.NoError(t, cache.Set(context.Background(), "user:1", "alice", 0))
+}
+
+func TestViaCep_DiskCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	dir := t.TempDir()
+	cache := newTestDiskCache(t, dir, WithDiskCacheClock(clock))
+
+	assert.NoError(t, cache.Set(ctx, "a", "alice", 0))
+	assert.NoError(t, cache.Set(ctx, "b", "bob", time.Minute))
+	assert.Error(t, cache.Set(ctx, "c", make(chan int), 0))
+
+	var dest string
+	assert.True(t, cache.Get(ctx, "a", &dest))
+	assert.False(t, cache.Get(ctx, "x", &dest))
+	var number int
+	assert.False(t, cache.Get(ctx, "a", &number))
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(2), stats.Entries)
+	assert.Positive(t, stats.Bytes)
+
+	clock.Advance(time.Minute)
+	assert.NoError(t, cache.Compact())
+
+	stats = cache.Stats()
+	assert.Equal(t, CacheStats{
+		Hits:           1,
+		Misses:         2,
+		Sets:           2,
+		SetErrors:      1,
+		Evictions:      1,
+		Entries:        1,
+		Bytes:          stats.Bytes,
+		DecodeFailures: 1,
+	}, stats)
+}
@@ -0,0 +1,126 @@
+package viacep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const maxBodySnippet = 512
+
+var (
+	// ErrInvalidCEP is returned when a CEP is malformed, either detected locally by ParseCEP
+	// or reported by the API with HTTP 400.
+	ErrInvalidCEP = errors.New("invalid cep")
+
+	// ErrNotFound is returned when the requested resource does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrCepNotFound is returned when ViaCEP reports that a well-formed CEP does not exist.
+	// It matches ErrNotFound with errors.Is.
+	ErrCepNotFound = fmt.Errorf("cep %w", ErrNotFound)
+
+	// ErrRateLimited is returned when the API answers with HTTP 429.
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrUpstreamUnavailable is returned when the API answers with a 5xx status or cannot be reached.
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+
+	// ErrCircuitOpen is returned by a CircuitBreaker that rejects a request without sending it.
+	// It matches ErrUpstreamUnavailable with errors.Is.
+	ErrCircuitOpen = fmt.Errorf("circuit breaker is open: %w", ErrUpstreamUnavailable)
+
+	// ErrInvalidSearch is matched by every *ValidationError returned for address search parameters.
+	ErrInvalidSearch = errors.New("invalid address search")
+
+	// ErrInvalidCallback is returned when a JSONP callback name is not a safe JavaScript identifier.
+	ErrInvalidCallback = errors.New("invalid jsonp callback")
+)
+
+// ValidationError reports an address search parameter rejected before any request is sent.
+type ValidationError struct {
+	// Field is the name of the rejected parameter: "uf", "cidade" or "logradouro".
+	Field string
+	// Value is the value as received.
+	Value string
+	// Reason describes why the value was rejected.
+	Reason string
+}
+
+// APIError describes a failed request to the API. It matches ErrInvalidCEP, ErrNotFound,
+// ErrRateLimited or ErrUpstreamUnavailable with errors.Is according to StatusCode, and
+// unwraps to the underlying transport or decoding error, if any. A 200 response that cannot
+// be decoded keeps its StatusCode and does not match ErrUpstreamUnavailable.
+type APIError struct {
+	// StatusCode is the HTTP status returned by the API, or 0 if no response was received.
+	StatusCode int
+	// URL is the requested URL.
+	URL string
+	// Body is the beginning of the response body, truncated to a few hundred bytes.
+	Body string
+	// Attempts is the number of requests sent, including retries.
+	Attempts int
+	// Err is the transport error that prevented a response from being received, or the error
+	// decoding a 200 response, if any.
+	Err error
+}
+
+func newAPIError(url string, statusCode int, body []byte, attempts int, err error) *APIError {
+	if len(body) > maxBodySnippet {
+		body = body[:maxBodySnippet]
+	}
+
+	return &APIError{
+		StatusCode: statusCode,
+		URL:        url,
+		Body:       string(body),
+		Attempts:   attempts,
+		Err:        err,
+	}
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s %q %s", ErrInvalidSearch, e.Field, e.Value, e.Reason)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidSearch
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil && e.StatusCode != 0 {
+		return fmt.Sprintf("failed to decode response from %s with status code %d after %d attempt(s): %v",
+			e.URL, e.StatusCode, e.Attempts, e.Err)
+	}
+
+	if e.Err != nil {
+		return fmt.Sprintf("failed to send GET request to %s after %d attempt(s): %v", e.URL, e.Attempts, e.Err)
+	}
+
+	return fmt.Sprintf("API request to %s returned status code %d after %d attempt(s); expected %d (OK)",
+		e.URL, e.StatusCode, e.Attempts, http.StatusOK)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrInvalidCEP:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrUpstreamUnavailable:
+		if e.Err != nil {
+			return e.StatusCode == 0 && !errors.Is(e.Err, context.Canceled)
+		}
+
+		return e.StatusCode >= http.StatusInternalServerError
+	default:
+		return false
+	}
+}
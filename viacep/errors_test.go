@@ -0,0 +1,96 @@
+package viacep
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViaCep_Errors_Sentinels(t *testing.T) {
+	assert.ErrorIs(t, ErrCepNotFound, ErrNotFound)
+	assert.EqualError(t, ErrCepNotFound, "cep not found")
+}
+
+func TestViaCep_Errors_APIError(t *testing.T) {
+	t.Run("status code error", func(t *testing.T) {
+		err := newAPIError("http://localhost/ws", http.StatusBadGateway, []byte("bad gateway"), 2, nil)
+		assert.EqualError(t, err, "API request to http://localhost/ws returned status code 502 after 2 attempt(s); expected 200 (OK)")
+		assert.Equal(t, "bad gateway", err.Body)
+		assert.NoError(t, errors.Unwrap(err))
+	})
+
+	t.Run("transport error", func(t *testing.T) {
+		cause := errors.New("connection refused")
+		err := newAPIError("http://localhost/ws", 0, nil, 1, cause)
+		assert.EqualError(t, err, "failed to send GET request to http://localhost/ws after 1 attempt(s): connection refused")
+		assert.ErrorIs(t, err, cause)
+		assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+	})
+
+	t.Run("decode error", func(t *testing.T) {
+		cause := errors.New("invalid character '<'")
+		err := newAPIError("http://localhost/ws", http.StatusOK, []byte("<html>"), 1, cause)
+		assert.EqualError(t, err, "failed to decode response from http://localhost/ws with status code 200 after 1 attempt(s): "+
+			"invalid character '<'")
+		assert.ErrorIs(t, err, cause)
+		assert.NotErrorIs(t, err, ErrUpstreamUnavailable)
+	})
+
+	t.Run("body snippet is truncated", func(t *testing.T) {
+		err := newAPIError("http://localhost/ws", http.StatusInternalServerError, []byte(strings.Repeat("x", 2*maxBodySnippet)), 1, nil)
+		assert.Len(t, err.Body, maxBodySnippet)
+	})
+
+	t.Run("sentinel matching", func(t *testing.T) {
+		testCases := []struct {
+			err      *APIError
+			matches  []error
+			excludes []error
+		}{
+			{
+				err:      &APIError{StatusCode: http.StatusBadRequest},
+				matches:  []error{ErrInvalidCEP},
+				excludes: []error{ErrNotFound, ErrRateLimited, ErrUpstreamUnavailable},
+			},
+			{
+				err:      &APIError{StatusCode: http.StatusNotFound},
+				matches:  []error{ErrNotFound},
+				excludes: []error{ErrCepNotFound, ErrInvalidCEP, ErrRateLimited, ErrUpstreamUnavailable},
+			},
+			{
+				err:      &APIError{StatusCode: http.StatusTooManyRequests},
+				matches:  []error{ErrRateLimited},
+				excludes: []error{ErrInvalidCEP, ErrNotFound, ErrUpstreamUnavailable},
+			},
+			{
+				err:      &APIError{StatusCode: http.StatusGatewayTimeout},
+				matches:  []error{ErrUpstreamUnavailable},
+				excludes: []error{ErrInvalidCEP, ErrNotFound, ErrRateLimited},
+			},
+			{
+				err:      &APIError{Err: context.DeadlineExceeded},
+				matches:  []error{ErrUpstreamUnavailable, context.DeadlineExceeded},
+				excludes: []error{ErrInvalidCEP, ErrNotFound, ErrRateLimited},
+			},
+			{
+				err:      &APIError{Err: context.Canceled},
+				matches:  []error{context.Canceled},
+				excludes: []error{ErrUpstreamUnavailable},
+			},
+		}
+
+		for _, tc := range testCases {
+			for _, target := range tc.matches {
+				assert.ErrorIs(t, tc.err, target)
+			}
+
+			for _, target := range tc.excludes {
+				assert.NotErrorIs(t, tc.err, target)
+			}
+		}
+	})
+}
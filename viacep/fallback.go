@@ -0,0 +1,100 @@
+package viacep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var errNoProviders = errors.New("fallback service: no providers configured")
+
+// AddressSearcher is implemented by providers that support searching addresses by street, such as ViaCep.
+type AddressSearcher interface {
+	Addresses(ctx context.Context, uf, cidade, logradouro string) ([]Address, error)
+}
+
+// FallbackService is a Service that queries providers in order, moving on to the next one
+// whenever a provider fails or does not know the CEP.
+type FallbackService struct {
+	providers []Provider
+}
+
+func NewFallbackService(providers ...Provider) *FallbackService {
+	return &FallbackService{
+		providers: providers,
+	}
+}
+
+// Cep returns the address from the first provider that finds it. The CEP is validated once,
+// before any provider is queried. If every provider reports the CEP as unknown the error
+// wraps ErrCepNotFound; otherwise it joins the error of each provider.
+func (f *FallbackService) Cep(ctx context.Context, cep string) (*Address, error) {
+	parsed, err := ParseCEP(cep)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := make([]error, 0, len(f.providers))
+	notFound := 0
+
+	for _, provider := range f.providers {
+		address, err := provider.Cep(ctx, parsed.String())
+		if err == nil {
+			return address, nil
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		if errors.Is(err, ErrCepNotFound) {
+			notFound++
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+	}
+
+	if len(errs) == 0 {
+		return nil, errNoProviders
+	}
+
+	if notFound == len(errs) {
+		return nil, fmt.Errorf("%w: %s", ErrCepNotFound, parsed)
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// Addresses searches with the first provider that implements AddressSearcher and falls back
+// to the next one on error. It returns an error wrapping errors.ErrUnsupported if no provider
+// supports searching.
+func (f *FallbackService) Addresses(ctx context.Context, uf, cidade, logradouro string) ([]Address, error) {
+	if _, err := newAddressQuery(uf, cidade, logradouro); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, provider := range f.providers {
+		searcher, ok := provider.(AddressSearcher)
+		if !ok {
+			continue
+		}
+
+		addresses, err := searcher.Addresses(ctx, uf, cidade, logradouro)
+		if err == nil {
+			return addresses, nil
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+	}
+
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("address search: %w", errors.ErrUnsupported)
+	}
+
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,172 @@
+package viacep
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubProvider struct {
+	name      string
+	address   *Address
+	addresses []Address
+	err       error
+	calls     []string
+}
+
+func (s *stubProvider) Name() string {
+	return s.name
+}
+
+func (s *stubProvider) Cep(_ context.Context, cep string) (*Address, error) {
+	s.calls = append(s.calls, cep)
+	return s.address, s.err
+}
+
+type stubSearcher struct {
+	stubProvider
+}
+
+func (s *stubSearcher) Addresses(_ context.Context, uf, cidade, logradouro string) ([]Address, error) {
+	s.calls = append(s.calls, uf+"/"+cidade+"/"+logradouro)
+	return s.addresses, s.err
+}
+
+func TestViaCep_FallbackService_Cep(t *testing.T) {
+	t.Run("first provider answers", func(t *testing.T) {
+		first := &stubProvider{name: "first", address: &Address{Cep: "01001-000"}}
+		second := &stubProvider{name: "second", address: &Address{Cep: "other"}}
+
+		address, err := NewFallbackService(first, second).Cep(context.Background(), "01001-000")
+		assert.NoError(t, err)
+		assert.Equal(t, &Address{Cep: "01001-000"}, address)
+		assert.Equal(t, []string{"01001000"}, first.calls)
+		assert.Empty(t, second.calls)
+	})
+
+	t.Run("falls back on error and not found", func(t *testing.T) {
+		first := &stubProvider{name: "first", err: ErrUpstreamUnavailable}
+		second := &stubProvider{name: "second", err: ErrCepNotFound}
+		third := &stubProvider{name: "third", address: &Address{Cep: "01001-000"}}
+
+		address, err := NewFallbackService(first, second, third).Cep(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, &Address{Cep: "01001-000"}, address)
+		assert.Len(t, second.calls, 1)
+	})
+
+	t.Run("every provider reports not found", func(t *testing.T) {
+		first := &stubProvider{name: "first", err: ErrCepNotFound}
+		second := &stubProvider{name: "second", err: ErrCepNotFound}
+
+		address, err := NewFallbackService(first, second).Cep(context.Background(), "99999999")
+		assert.Nil(t, address)
+		assert.EqualError(t, err, "cep not found: 99999999")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("every provider fails", func(t *testing.T) {
+		first := &stubProvider{name: "first", err: ErrCepNotFound}
+		second := &stubProvider{name: "second", err: ErrRateLimited}
+
+		address, err := NewFallbackService(first, second).Cep(context.Background(), "99999999")
+		assert.Nil(t, address)
+		assert.EqualError(t, err, "first: cep not found\nsecond: rate limited")
+		assert.ErrorIs(t, err, ErrRateLimited)
+	})
+
+	t.Run("invalid cep", func(t *testing.T) {
+		first := &stubProvider{name: "first"}
+
+		address, err := NewFallbackService(first).Cep(context.Background(), "invalid")
+		assert.Nil(t, address)
+		assert.ErrorIs(t, err, ErrInvalidCEP)
+		assert.Empty(t, first.calls)
+	})
+
+	t.Run("canceled context stops fallback", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		first := &stubProvider{name: "first", err: errors.New("canceled")}
+		second := &stubProvider{name: "second", address: &Address{}}
+
+		address, err := NewFallbackService(first, second).Cep(ctx, "01001000")
+		assert.Nil(t, address)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, second.calls)
+	})
+
+	t.Run("no providers", func(t *testing.T) {
+		address, err := NewFallbackService().Cep(context.Background(), "01001000")
+		assert.Nil(t, address)
+		assert.EqualError(t, err, "fallback service: no providers configured")
+	})
+
+	t.Run("viacep outage falls back to brasilapi", func(t *testing.T) {
+		srv := newFixtureServer(t, map[string]fixture{
+			"/api/cep/v2/01001000": {http.StatusOK, "brasilapi_01001000.json"},
+		})
+
+		viaCep := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+		brasilAPI := NewBrasilAPI(WithProviderHTTP(NewHTTPClient(0)), WithProviderBaseURL(srv.URL))
+
+		var service Service = NewFallbackService(viaCep, brasilAPI)
+		address, err := service.Cep(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, "Praça da Sé", address.Logradouro)
+	})
+}
+
+func TestViaCep_FallbackService_Addresses(t *testing.T) {
+	t.Run("providers without search are skipped", func(t *testing.T) {
+		first := &stubProvider{name: "first"}
+		second := &stubSearcher{stubProvider{name: "second", err: ErrUpstreamUnavailable}}
+		third := &stubSearcher{stubProvider{name: "third", addresses: []Address{{Cep: "91790-072"}}}}
+
+		addresses, err := NewFallbackService(first, second, third).Addresses(context.Background(), "RS", "Porto Alegre", "Domingos")
+		assert.NoError(t, err)
+		assert.Equal(t, []Address{{Cep: "91790-072"}}, addresses)
+		assert.Empty(t, first.calls)
+		assert.Equal(t, []string{"RS/Porto Alegre/Domingos"}, second.calls)
+	})
+
+	t.Run("every searcher fails", func(t *testing.T) {
+		first := &stubSearcher{stubProvider{name: "first", err: ErrUpstreamUnavailable}}
+
+		addresses, err := NewFallbackService(first).Addresses(context.Background(), "RS", "Porto Alegre", "Domingos")
+		assert.Nil(t, addresses)
+		assert.EqualError(t, err, "first: upstream unavailable")
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		addresses, err := NewFallbackService(NewBrasilAPI()).Addresses(context.Background(), "RS", "Porto Alegre", "Domingos")
+		assert.Nil(t, addresses)
+		assert.ErrorIs(t, err, errors.ErrUnsupported)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		first := &stubSearcher{stubProvider{name: "first"}}
+
+		addresses, err := NewFallbackService(first).Addresses(context.Background(), "XX", "Porto Alegre", "Domingos")
+		assert.Nil(t, addresses)
+		assert.ErrorIs(t, err, ErrInvalidSearch)
+		assert.Empty(t, first.calls)
+	})
+
+	t.Run("canceled context stops fallback", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		first := &stubSearcher{stubProvider{name: "first", err: errors.New("canceled")}}
+		second := &stubSearcher{stubProvider{name: "second"}}
+
+		addresses, err := NewFallbackService(first, second).Addresses(ctx, "RS", "Porto Alegre", "Domingos")
+		assert.Nil(t, addresses)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, second.calls)
+	})
+}
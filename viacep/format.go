@@ -0,0 +1,144 @@
+package viacep
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Format is a response format served by ViaCEP.
+type Format string
+
+const (
+	// FormatJSON is the default format, e.g. {"cep": "01001-000", ...}.
+	FormatJSON Format = "json"
+	// FormatXML wraps the address in an <xmlcep> element, e.g. <xmlcep><cep>01001-000</cep>...</xmlcep>.
+	FormatXML Format = "xml"
+	// FormatPiped separates "key:value" pairs with "|", e.g. cep:01001-000|logradouro:Praça da Sé|...
+	// ViaCEP only serves it for CEP lookups.
+	FormatPiped Format = "piped"
+	// FormatQuerty encodes the address as a query string, e.g. cep=01001-000&logradouro=Pra%C3%A7a+da+S%C3%A9&...
+	// ViaCEP only serves it for CEP lookups.
+	FormatQuerty Format = "querty"
+)
+
+// xmlResponse is the <xmlcep> document returned for both CEP lookups and address searches.
+type xmlResponse struct {
+	XMLName xml.Name `xml:"xmlcep"`
+	Address
+	Erro      erroFlag  `xml:"erro"`
+	Enderecos []Address `xml:"enderecos>endereco"`
+}
+
+// validate returns an error wrapping errors.ErrUnsupported if ViaCEP does not serve f, or does
+// not serve it for address searches when search is true.
+func (f Format) validate(search bool) error {
+	switch f {
+	case FormatJSON, FormatXML:
+		return nil
+	case FormatPiped, FormatQuerty:
+		if !search {
+			return nil
+		}
+
+		return fmt.Errorf("address search in %s format: %w", f, errors.ErrUnsupported)
+	default:
+		return fmt.Errorf("format %q: %w", f, errors.ErrUnsupported)
+	}
+}
+
+// parseCep decodes a CEP lookup response in the given format, including the "erro" payload
+// ViaCEP returns for unknown CEPs.
+func parseCep(format Format, body []byte) (cepResponse, error) {
+	var resp cepResponse
+
+	switch format {
+	case FormatJSON:
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return cepResponse{}, fmt.Errorf("failed to decode json response: %w", err)
+		}
+	case FormatXML:
+		var doc xmlResponse
+		if err := xml.Unmarshal(body, &doc); err != nil {
+			return cepResponse{}, fmt.Errorf("failed to decode xml response: %w", err)
+		}
+
+		resp = cepResponse{Address: doc.Address, Erro: doc.Erro}
+	case FormatPiped:
+		fields, err := parsePiped(body)
+		if err != nil {
+			return cepResponse{}, err
+		}
+
+		resp = fieldsToCepResponse(fields)
+	case FormatQuerty:
+		fields, err := url.ParseQuery(string(body))
+		if err != nil {
+			return cepResponse{}, fmt.Errorf("failed to decode querty response: %w", err)
+		}
+
+		resp = fieldsToCepResponse(func(key string) string { return fields.Get(key) })
+	default:
+		return cepResponse{}, format.validate(false)
+	}
+
+	return resp, nil
+}
+
+// parseAddresses decodes an address search response in the given format.
+func parseAddresses(format Format, body []byte) ([]Address, error) {
+	switch format {
+	case FormatJSON:
+		var addresses []Address
+		if err := json.Unmarshal(body, &addresses); err != nil {
+			return nil, fmt.Errorf("failed to decode json response: %w", err)
+		}
+
+		return addresses, nil
+	case FormatXML:
+		var doc xmlResponse
+		if err := xml.Unmarshal(body, &doc); err != nil {
+			return nil, fmt.Errorf("failed to decode xml response: %w", err)
+		}
+
+		return doc.Enderecos, nil
+	default:
+		return nil, format.validate(true)
+	}
+}
+
+// parsePiped splits a piped response into a lookup function over its keys.
+func parsePiped(body []byte) (func(key string) string, error) {
+	fields := map[string]string{}
+	for _, pair := range strings.Split(strings.TrimSpace(string(body)), "|") {
+		key, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("failed to decode piped response: invalid pair %q", pair)
+		}
+
+		fields[key] = value
+	}
+
+	return func(key string) string { return fields[key] }, nil
+}
+
+func fieldsToCepResponse(field func(key string) string) cepResponse {
+	resp := cepResponse{Erro: field("erro") == "true"}
+	for key, value := range addressFieldsByKey(&resp.Address) {
+		*value = field(key)
+	}
+
+	return resp
+}
+
+// addressFieldsByKey maps the keys ViaCEP uses in every format to the fields of a.
+func addressFieldsByKey(a *Address) map[string]*string {
+	return map[string]*string{
+		"cep": &a.Cep, "logradouro": &a.Logradouro, "complemento": &a.Complemento, "unidade": &a.Unidade,
+		"bairro": &a.Bairro, "localidade": &a.Localidade, "uf": &a.Uf, "estado": &a.Estado,
+		"regiao": &a.Regiao, "ibge": &a.Ibge, "gia": &a.Gia, "ddd": &a.Ddd, "siafi": &a.Siafi,
+	}
+}
@@ -0,0 +1,201 @@
+package viacep
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	addressSe = Address{
+		Cep: "01001-000", Logradouro: "Praça da Sé", Complemento: "lado ímpar", Bairro: "Sé", Localidade: "São Paulo",
+		Uf: "SP", Estado: "São Paulo", Regiao: "Sudeste", Ibge: "3550308", Gia: "1004", Ddd: "11", Siafi: "7107",
+	}
+	addressesDomingos = []Address{
+		{
+			Cep: "91790-072", Logradouro: "Rua Domingos José Poli", Bairro: "Restinga", Localidade: "Porto Alegre",
+			Uf: "RS", Estado: "Rio Grande do Sul", Regiao: "Sul", Ibge: "4314902", Ddd: "51", Siafi: "8801",
+		},
+		{
+			Cep: "91420-270", Logradouro: "Rua Domingos da Silva", Bairro: "Bom Jesus", Localidade: "Porto Alegre",
+			Uf: "RS", Estado: "Rio Grande do Sul", Regiao: "Sul", Ibge: "4314902", Ddd: "51", Siafi: "8801",
+		},
+	}
+	allFormats = []Format{FormatJSON, FormatXML, FormatPiped, FormatQuerty}
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	payload, err := os.ReadFile(filepath.Join("testdata", name))
+	assert.NoError(t, err)
+	return payload
+}
+
+func TestViaCep_Format_validate(t *testing.T) {
+	for _, format := range allFormats {
+		assert.NoError(t, format.validate(false))
+	}
+
+	assert.NoError(t, FormatJSON.validate(true))
+	assert.NoError(t, FormatXML.validate(true))
+
+	err := FormatPiped.validate(true)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+	assert.EqualError(t, err, "address search in piped format: unsupported operation")
+
+	err = Format("yaml").validate(false)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+	assert.EqualError(t, err, `format "yaml": unsupported operation`)
+}
+
+func TestViaCep_Format_parseCep(t *testing.T) {
+	for _, format := range allFormats {
+		t.Run(string(format), func(t *testing.T) {
+			resp, err := parseCep(format, readFixture(t, "viacep_01001000."+string(format)))
+			assert.NoError(t, err)
+			assert.False(t, bool(resp.Erro))
+			assert.Equal(t, addressSe, resp.Address)
+
+			resp, err = parseCep(format, readFixture(t, "viacep_not_found."+string(format)))
+			assert.NoError(t, err)
+			assert.True(t, bool(resp.Erro))
+			assert.Equal(t, Address{}, resp.Address)
+		})
+	}
+
+	t.Run("malformed payloads", func(t *testing.T) {
+		_, err := parseCep(FormatJSON, []byte(`{"cep":`))
+		assert.ErrorContains(t, err, "failed to decode json response")
+
+		_, err = parseCep(FormatXML, []byte(`<html><body>Bad Request</body></html>`))
+		assert.ErrorContains(t, err, "failed to decode xml response")
+
+		_, err = parseCep(FormatPiped, []byte(`cep:01001-000|garbage`))
+		assert.EqualError(t, err, `failed to decode piped response: invalid pair "garbage"`)
+
+		_, err = parseCep(FormatQuerty, []byte(`cep=%zz`))
+		assert.ErrorContains(t, err, "failed to decode querty response")
+
+		_, err = parseCep(Format("yaml"), nil)
+		assert.ErrorIs(t, err, errors.ErrUnsupported)
+	})
+}
+
+func TestViaCep_Format_parseAddresses(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatXML} {
+		t.Run(string(format), func(t *testing.T) {
+			addresses, err := parseAddresses(format, readFixture(t, "viacep_search."+string(format)))
+			assert.NoError(t, err)
+			assert.Equal(t, addressesDomingos, addresses)
+
+			addresses, err = parseAddresses(format, readFixture(t, "viacep_search_empty."+string(format)))
+			assert.NoError(t, err)
+			assert.Empty(t, addresses)
+		})
+	}
+
+	t.Run("malformed payloads", func(t *testing.T) {
+		_, err := parseAddresses(FormatJSON, []byte(`{"erro": true}`))
+		assert.ErrorContains(t, err, "failed to decode json response")
+
+		_, err = parseAddresses(FormatXML, []byte(`<xmlcep>`))
+		assert.ErrorContains(t, err, "failed to decode xml response")
+
+		_, err = parseAddresses(FormatPiped, nil)
+		assert.ErrorIs(t, err, errors.ErrUnsupported)
+	})
+}
+
+func TestViaCep_Format_ViaCep(t *testing.T) {
+	routes := map[string]fixture{
+		"/ws/RS/Porto Alegre/Domingos/json/": {http.StatusOK, "viacep_search.json"},
+		"/ws/RS/Porto Alegre/Domingos/xml/":  {http.StatusOK, "viacep_search.xml"},
+	}
+	for _, format := range allFormats {
+		routes["/ws/01001000/"+string(format)+"/"] = fixture{http.StatusOK, "viacep_01001000." + string(format)}
+		routes["/ws/99999999/"+string(format)+"/"] = fixture{http.StatusOK, "viacep_not_found." + string(format)}
+	}
+
+	srv := newFixtureServer(t, routes)
+	ctx := context.Background()
+
+	for _, format := range allFormats {
+		t.Run(string(format), func(t *testing.T) {
+			c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache(), WithFormat(format))
+
+			address, err := c.Cep(ctx, "01001-000")
+			assert.NoError(t, err)
+			assert.Equal(t, &addressSe, address)
+
+			address, err = c.Cep(ctx, "99999999")
+			assert.Nil(t, address)
+			assert.ErrorIs(t, err, ErrCepNotFound)
+
+			raw, err := c.CepRaw(ctx, "01001000", format)
+			assert.NoError(t, err)
+			assert.Equal(t, readFixture(t, "viacep_01001000."+string(format)), raw)
+
+			raw, err = c.CepRaw(ctx, "99999999", format)
+			assert.NoError(t, err)
+			assert.Equal(t, readFixture(t, "viacep_not_found."+string(format)), raw)
+		})
+	}
+
+	t.Run("addresses", func(t *testing.T) {
+		for _, format := range []Format{FormatJSON, FormatXML} {
+			c := New(WithHTTP(NewStdHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache(), WithFormat(format))
+
+			addresses, err := c.Addresses(ctx, "RS", "Porto Alegre", "Domingos")
+			assert.NoError(t, err)
+			assert.Equal(t, addressesDomingos, addresses)
+
+			raw, err := c.AddressesRaw(ctx, "RS", "Porto Alegre", "Domingos", format)
+			assert.NoError(t, err)
+			assert.Equal(t, readFixture(t, "viacep_search."+string(format)), raw)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache(), WithFormat(FormatPiped))
+
+		addresses, err := c.Addresses(ctx, "RS", "Porto Alegre", "Domingos")
+		assert.Nil(t, addresses)
+		assert.ErrorIs(t, err, errors.ErrUnsupported)
+
+		raw, err := c.AddressesRaw(ctx, "RS", "Porto Alegre", "Domingos", FormatQuerty)
+		assert.Nil(t, raw)
+		assert.ErrorIs(t, err, errors.ErrUnsupported)
+
+		raw, err = c.CepRaw(ctx, "01001000", Format("yaml"))
+		assert.Nil(t, raw)
+		assert.ErrorIs(t, err, errors.ErrUnsupported)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+
+		_, err := c.CepRaw(ctx, "0100", FormatXML)
+		assert.ErrorIs(t, err, ErrInvalidCEP)
+
+		_, err = c.AddressesRaw(ctx, "XX", "Porto Alegre", "Domingos", FormatXML)
+		assert.ErrorIs(t, err, ErrInvalidSearch)
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache(), WithFormat(FormatXML))
+
+		raw, err := c.CepRaw(ctx, "00000000", FormatXML)
+		assert.Nil(t, raw)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		address, err := c.Cep(ctx, "00000000")
+		assert.Nil(t, address)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -22,23 +22,84 @@ type HTTP interface {
 	//   - ctx: The context to manage the request lifecycle, such as timeouts or cancellations.
 	//   - url: The URL to which the GET request will be sent.
 	//   - dest:  A pointer to the variable where the response data will be stored. The type of
-	//            dest should be a pointer to an object that matches the expected data structure.
+	//            dest should be a pointer to an object that matches the expected data structure,
+	//            or a *[]byte to receive the response body as is, without JSON decoding.
 	//
 	// Returns:
-	//   - error: If an error occurs during the request, it will be returned. Otherwise, nil is returned.
+	//   - error: If the request fails or the API answers with a status other than 200, an *APIError
+	//            is returned. Otherwise, nil is returned.
 	Get(ctx context.Context, url string, dest any) error
 }
 
 type HTTPClient struct {
 	restyClient *resty.Client
+	retrier     retrier
 }
 
-func NewHTTPClient(maxRetry int) *HTTPClient {
-	restyHTTPClient := resty.New()
-	restyHTTPClient.SetRetryCount(maxRetry).SetRetryWaitTime(retryWaitTime)
+// HTTPOption configures the clients created with NewHTTPClient and NewStdHTTPClient.
+type HTTPOption func(*httpConfig)
+
+type httpConfig struct {
+	retrier   retrier
+	client    *http.Client
+	transport http.RoundTripper
+}
+
+// WithRetryPolicy replaces the retry policy, including the maxRetry given to the constructor.
+func WithRetryPolicy(policy RetryPolicy) HTTPOption {
+	return func(c *httpConfig) {
+		c.retrier.policy = policy
+	}
+}
+
+// WithStdClient sets the *http.Client used to send requests, e.g. to configure timeouts or
+// proxies. The client is copied, so later changes to it have no effect.
+func WithStdClient(client *http.Client) HTTPOption {
+	return func(c *httpConfig) {
+		c.client = client
+	}
+}
+
+// WithTransport sets the RoundTripper used to send requests, replacing the transport of the
+// client given to WithStdClient, if any.
+func WithTransport(transport http.RoundTripper) HTTPOption {
+	return func(c *httpConfig) {
+		c.transport = transport
+	}
+}
+
+func newHTTPConfig(maxRetry int, opts []HTTPOption) httpConfig {
+	c := httpConfig{retrier: newRetrier(DefaultRetryPolicy(maxRetry))}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// stdClient returns a copy of the configured *http.Client with the configured transport.
+func (c httpConfig) stdClient() *http.Client {
+	client := &http.Client{}
+	if c.client != nil {
+		*client = *c.client
+	}
+
+	if c.transport != nil {
+		client.Transport = c.transport
+	}
+
+	return client
+}
+
+// NewHTTPClient creates an HTTPClient that retries failed requests up to maxRetry times
+// following DefaultRetryPolicy.
+func NewHTTPClient(maxRetry int, opts ...HTTPOption) *HTTPClient {
+	config := newHTTPConfig(maxRetry, opts)
 
 	return &HTTPClient{
-		restyClient: restyHTTPClient,
+		// Retries are handled by the retrier, so resty sends each request once.
+		restyClient: resty.NewWithClient(config.stdClient()).SetRetryCount(0),
+		retrier:     config.retrier,
 	}
 }
 
@@ -47,15 +108,29 @@ func (r *HTTPClient) Get(ctx context.Context, url string, dest any) error {
 		return fmt.Errorf("expected a pointer for 'dest', but got %s", reflect.TypeOf(dest))
 	}
 
-	req := r.restyClient.R().SetContext(ctx)
-	resp, err := req.SetHeaders(headersDefault).SetResult(dest).Get(url)
-	if err != nil {
-		return fmt.Errorf("failed to send GET request to %s: %w", url, err)
-	}
+	raw, isRaw := dest.(*[]byte)
 
-	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("API request to %s returned status code %d; expected %d (OK)", resp.Request.URL, resp.StatusCode(), http.StatusOK)
-	}
+	return r.retrier.run(ctx, url, func(ctx context.Context) attempt {
+		req := r.restyClient.R().SetContext(ctx).SetHeaders(headersDefault)
+		if !isRaw {
+			// Decode every 200 as JSON, like StdHTTPClient, instead of skipping other content types.
+			req.SetResult(dest).ForceContentType("application/json")
+		}
+
+		resp, err := req.Get(url)
+		if err != nil && resp.StatusCode() == http.StatusOK && !isNetworkError(err) {
+			// The response arrived but its body could not be decoded into dest.
+			return attempt{statusCode: resp.StatusCode(), header: resp.Header(), body: resp.Body(), err: err}
+		}
+
+		if err != nil {
+			return attempt{err: err}
+		}
+
+		if isRaw && resp.StatusCode() == http.StatusOK {
+			*raw = resp.Body()
+		}
 
-	return nil
+		return attempt{statusCode: resp.StatusCode(), header: resp.Header(), body: resp.Body()}
+	})
 }
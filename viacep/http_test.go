@@ -2,16 +2,55 @@ package viacep
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// newFlakyServer answers failures times with status, and a Retry-After of 2s for 429, before
+// answering 200. It returns the server and its request counter.
+func newFlakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if hits.Add(1) <= failures {
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "2")
+			}
+
+			w.WriteHeader(status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"key": "value"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &hits
+}
+
+// withRetrySleep replaces the wait between retries.
+func withRetrySleep(sleep func(ctx context.Context, d time.Duration) error) HTTPOption {
+	return func(c *httpConfig) {
+		c.retrier.sleep = sleep
+	}
+}
+
 func TestViaCep_HttpClient_Get(t *testing.T) {
+	testHTTPConformance(t, func(maxRetry int, opts ...HTTPOption) HTTP {
+		return NewHTTPClient(maxRetry, opts...)
+	})
+}
+
+// testHTTPConformance checks the behaviour every HTTP implementation of this package shares:
+// decoding, *APIError values, retries and context handling.
+func testHTTPConformance(t *testing.T, newClient func(maxRetry int, opts ...HTTPOption) HTTP) {
 	t.Run("successful GET request", func(t *testing.T) {
 		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
@@ -23,7 +62,7 @@ func TestViaCep_HttpClient_Get(t *testing.T) {
 		}))
 		defer srv.Close()
 
-		client := NewHTTPClient(1)
+		client := newClient(1)
 
 		dest := map[string]string{}
 		err := client.Get(context.Background(), srv.URL, &dest)
@@ -31,8 +70,56 @@ func TestViaCep_HttpClient_Get(t *testing.T) {
 		assert.Equal(t, map[string]string{"key": "value"}, dest)
 	})
 
+	t.Run("raw body", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"key": "value"}`))
+		}))
+		defer srv.Close()
+
+		var raw []byte
+		err := newClient(0).Get(context.Background(), srv.URL, &raw)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"key": "value"}`, string(raw))
+	})
+
+	t.Run("undecodable body", func(t *testing.T) {
+		testCases := []struct {
+			contentType string
+			body        string
+		}{
+			{"application/json", `{"key": `},
+			{"text/html; charset=utf-8", "<html><body>maintenance</body></html>"},
+		}
+
+		for _, tc := range testCases {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				hits.Add(1)
+				w.Header().Set("Content-Type", tc.contentType)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+
+			client := newClient(1, WithRetryPolicy(RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond}))
+
+			dest := map[string]string{}
+			err := client.Get(context.Background(), srv.URL, &dest)
+			srv.Close()
+
+			var apiErr *APIError
+			assert.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, http.StatusOK, apiErr.StatusCode)
+			assert.Equal(t, tc.body, apiErr.Body)
+			assert.Error(t, apiErr.Err)
+			assert.ErrorContains(t, err, "failed to decode response")
+			assert.NotErrorIs(t, err, ErrUpstreamUnavailable)
+			assert.Equal(t, int32(1), hits.Load(), "decode errors are not retried")
+			assert.Empty(t, dest)
+		}
+	})
+
 	t.Run("invalid dest type", func(t *testing.T) {
-		client := NewHTTPClient(1)
+		client := newClient(1)
 
 		invalidDest := "string_instead_of_pointer"
 		err := client.Get(context.Background(), "http://", invalidDest)
@@ -40,26 +127,165 @@ func TestViaCep_HttpClient_Get(t *testing.T) {
 	})
 
 	t.Run("non-ok status code", func(t *testing.T) {
-		errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-			w.WriteHeader(http.StatusInternalServerError)
-		}))
+		testCases := []struct {
+			status   int
+			sentinel error
+		}{
+			{http.StatusBadRequest, ErrInvalidCEP},
+			{http.StatusNotFound, ErrNotFound},
+			{http.StatusTooManyRequests, ErrRateLimited},
+			{http.StatusInternalServerError, ErrUpstreamUnavailable},
+			{http.StatusBadGateway, ErrUpstreamUnavailable},
+			{http.StatusServiceUnavailable, ErrUpstreamUnavailable},
+		}
 
-		defer errorServer.Close()
+		for _, tc := range testCases {
+			errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte("<h1>error</h1>"))
+			}))
+
+			client := newClient(0)
+
+			dest := map[string]string{}
+			err := client.Get(context.Background(), errorServer.URL, &dest)
+			errorServer.Close()
+
+			var apiErr *APIError
+			assert.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, tc.status, apiErr.StatusCode)
+			assert.Equal(t, errorServer.URL, apiErr.URL)
+			assert.Equal(t, "<h1>error</h1>", apiErr.Body)
+			assert.Equal(t, 1, apiErr.Attempts)
+			assert.NoError(t, apiErr.Err)
+			assert.ErrorIs(t, err, tc.sentinel)
+		}
+	})
 
-		client := NewHTTPClient(1)
+	t.Run("retries until success", func(t *testing.T) {
+		srv, hits := newFlakyServer(t, 2, http.StatusServiceUnavailable)
+
+		client := newClient(3, WithRetryPolicy(RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond}))
 
 		dest := map[string]string{}
-		err := client.Get(context.Background(), errorServer.URL, &dest)
-		assert.EqualError(t, err, fmt.Sprintf("API request to %s returned status code 500; expected 200 (OK)", errorServer.URL))
+		err := client.Get(context.Background(), srv.URL, &dest)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"key": "value"}, dest)
+		assert.Equal(t, int32(3), hits.Load())
+	})
+
+	t.Run("retry attempts", func(t *testing.T) {
+		srv, hits := newFlakyServer(t, 10, http.StatusServiceUnavailable)
+
+		client := newClient(2, WithRetryPolicy(RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}))
+
+		dest := map[string]string{}
+		err := client.Get(context.Background(), srv.URL, &dest)
+
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 3, apiErr.Attempts)
+		assert.Equal(t, int32(3), hits.Load())
+		assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+		assert.Contains(t, err.Error(), "after 3 attempt(s)")
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		for _, status := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError} {
+			srv, hits := newFlakyServer(t, 1, status)
+
+			client := newClient(3, WithRetryPolicy(RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond}))
+
+			err := client.Get(context.Background(), srv.URL, &map[string]string{})
+
+			var apiErr *APIError
+			assert.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, status, apiErr.StatusCode)
+			assert.Equal(t, 1, apiErr.Attempts)
+			assert.Equal(t, int32(1), hits.Load())
+		}
+	})
+
+	t.Run("not found body is not retried", func(t *testing.T) {
+		var hits atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"erro": "true"}`))
+		}))
+		defer srv.Close()
+
+		client := newClient(3, WithRetryPolicy(RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond}))
+
+		var resp cepResponse
+		assert.NoError(t, client.Get(context.Background(), srv.URL, &resp))
+		assert.True(t, bool(resp.Erro))
+		assert.Equal(t, int32(1), hits.Load())
+	})
+
+	t.Run("honours retry-after", func(t *testing.T) {
+		srv, hits := newFlakyServer(t, 1, http.StatusTooManyRequests)
+
+		var sleeps []time.Duration
+		client := newClient(1, withRetrySleep(func(_ context.Context, d time.Duration) error {
+			sleeps = append(sleeps, d)
+			return nil
+		}))
+
+		assert.NoError(t, client.Get(context.Background(), srv.URL, &map[string]string{}))
+		assert.Equal(t, []time.Duration{2 * time.Second}, sleeps)
+		assert.Equal(t, int32(2), hits.Load())
+	})
+
+	t.Run("stops within the context deadline", func(t *testing.T) {
+		srv, hits := newFlakyServer(t, 10, http.StatusTooManyRequests)
+
+		client := newClient(5)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		start := time.Now()
+		err := client.Get(ctx, srv.URL, &map[string]string{})
+		assert.Less(t, time.Since(start), time.Second)
+		assert.ErrorIs(t, err, ErrRateLimited)
+		assert.Equal(t, int32(1), hits.Load(), "Retry-After of 2s does not fit the deadline")
+	})
+
+	t.Run("network errors are retried", func(t *testing.T) {
+		var hits atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if hits.Add(1) == 1 {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				_ = conn.Close()
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"key": "value"}`))
+		}))
+		defer srv.Close()
+
+		client := newClient(1, WithRetryPolicy(RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond}))
+
+		assert.NoError(t, client.Get(context.Background(), srv.URL, &map[string]string{}))
+		assert.Equal(t, int32(2), hits.Load())
 	})
 
 	t.Run("HTTP request error", func(t *testing.T) {
-		client := NewHTTPClient(0)
+		client := newClient(0)
 		url := "httpdd://invalid-url"
 		dest := map[string]string{}
 
 		err := client.Get(context.Background(), url, &dest)
-		assert.EqualError(t, err, fmt.Sprintf("failed to send GET request to %s: Get \"httpdd://invalid-url\": unsupported protocol scheme \"httpdd\"", url))
+
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 0, apiErr.StatusCode)
+		assert.Equal(t, url, apiErr.URL)
+		assert.Equal(t, 1, apiErr.Attempts)
+		assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+		assert.EqualError(t, apiErr.Err, "Get \"httpdd://invalid-url\": unsupported protocol scheme \"httpdd\"")
 	})
 
 	t.Run("timeout", func(t *testing.T) {
@@ -68,13 +294,29 @@ func TestViaCep_HttpClient_Get(t *testing.T) {
 		}))
 		defer errorServer.Close()
 
-		client := NewHTTPClient(0)
+		client := newClient(0)
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
 		defer cancel()
 
 		dest := map[string]string{}
 		err := client.Get(ctx, errorServer.URL, &dest)
-		assert.EqualError(t, err, fmt.Sprintf("failed to send GET request to %s: Get %q: context deadline exceeded", errorServer.URL, errorServer.URL))
+
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		client := newClient(0)
+
+		dest := map[string]string{}
+		err := client.Get(ctx, "http://127.0.0.1:0", &dest)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NotErrorIs(t, err, ErrUpstreamUnavailable)
 	})
 }
@@ -0,0 +1,122 @@
+package viacep
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// maxCallbackLength bounds JSONP callback names; real ones are short and long ones are suspicious.
+const maxCallbackLength = 128
+
+// callbackPattern accepts JavaScript identifiers, optionally namespaced with dots, e.g. "cb" or
+// "app.handlers.onCep". Anything that could break out of the wrapper, such as parentheses,
+// quotes, brackets or whitespace, is rejected.
+var callbackPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// CepJSONP returns the ViaCEP JSONP response for cep wrapped in callback, byte for byte, after
+// checking that the wrapper matches. The payload ViaCEP sends for an unknown CEP is returned as
+// is, without an error. JSONP responses are not cached.
+func (v *ViaCep) CepJSONP(ctx context.Context, cep, callback string) ([]byte, error) {
+	parsed, err := ParseCEP(cep)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.jsonp(ctx, string(parsed), callback)
+}
+
+// AddressesJSONP returns the ViaCEP JSONP response of an address search wrapped in callback,
+// byte for byte, after checking that the wrapper matches. JSONP responses are not cached.
+func (v *ViaCep) AddressesJSONP(ctx context.Context, uf, cidade, logradouro, callback string) ([]byte, error) {
+	query, err := newAddressQuery(uf, cidade, logradouro)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.jsonp(ctx, query.path(), callback)
+}
+
+func (v *ViaCep) jsonp(ctx context.Context, path, callback string) ([]byte, error) {
+	if err := validateCallback(callback); err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	url := v.formatURL(path, FormatJSON) + "?callback=" + url.QueryEscape(callback)
+	if err := v.get(ctx, url, &body); err != nil {
+		return nil, err
+	}
+
+	if _, err := StripJSONP(body, callback); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// StripJSONP removes the callback(...) wrapper from a JSONP payload and returns the JSON inside.
+// It fails with ErrInvalidCallback if callback is unsafe, and with an error if the payload is
+// not wrapped in exactly that callback or does not hold valid JSON.
+func StripJSONP(payload []byte, callback string) ([]byte, error) {
+	if err := validateCallback(callback); err != nil {
+		return nil, err
+	}
+
+	body := bytes.TrimSpace(payload)
+	body = bytes.TrimSpace(bytes.TrimSuffix(body, []byte(";")))
+
+	prefix := []byte(callback + "(")
+	if !bytes.HasPrefix(body, prefix) || !bytes.HasSuffix(body, []byte(")")) {
+		return nil, fmt.Errorf("malformed jsonp response: expected %s(...)", callback)
+	}
+
+	inner := body[len(prefix) : len(body)-1]
+	if !json.Valid(inner) {
+		return nil, fmt.Errorf("malformed jsonp response: %s(...) does not wrap valid json", callback)
+	}
+
+	return inner, nil
+}
+
+// DecodeJSONP strips the callback wrapper from payload and decodes the JSON inside into dest.
+// When dest is an *Address, the "erro" payload ViaCEP sends for an unknown CEP is reported as
+// ErrCepNotFound instead of leaving dest empty.
+func DecodeJSONP(payload []byte, callback string, dest any) error {
+	inner, err := StripJSONP(payload, callback)
+	if err != nil {
+		return err
+	}
+
+	address, ok := dest.(*Address)
+	if !ok {
+		if err := json.Unmarshal(inner, dest); err != nil {
+			return fmt.Errorf("failed to decode jsonp response: %w", err)
+		}
+
+		return nil
+	}
+
+	var resp cepResponse
+	if err := json.Unmarshal(inner, &resp); err != nil {
+		return fmt.Errorf("failed to decode jsonp response: %w", err)
+	}
+
+	if resp.Erro {
+		return ErrCepNotFound
+	}
+
+	*address = resp.Address
+	return nil
+}
+
+func validateCallback(callback string) error {
+	if len(callback) > maxCallbackLength || !callbackPattern.MatchString(callback) {
+		return fmt.Errorf("%w: %q", ErrInvalidCallback, callback)
+	}
+
+	return nil
+}
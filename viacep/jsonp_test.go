@@ -0,0 +1,166 @@
+package viacep
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViaCep_JSONP_validateCallback(t *testing.T) {
+	for _, callback := range []string{"callback", "cb_1", "$jsonp", "app.handlers.onCep", "_"} {
+		assert.NoError(t, validateCallback(callback), callback)
+	}
+
+	unsafe := []string{
+		"", "1cb", "alert(1)", "cb;alert(1)", "cb//", "a..b", "a.", ".a", "cb x", "cb\n", "a[0]",
+		"<script>", `cb"`, "açaí", strings.Repeat("a", maxCallbackLength+1),
+	}
+	for _, callback := range unsafe {
+		err := validateCallback(callback)
+		assert.ErrorIs(t, err, ErrInvalidCallback, callback)
+	}
+
+	assert.EqualError(t, validateCallback("alert(1)"), `invalid jsonp callback: "alert(1)"`)
+}
+
+func TestViaCep_JSONP_StripJSONP(t *testing.T) {
+	t.Run("fixtures", func(t *testing.T) {
+		for _, name := range []string{"viacep_01001000", "viacep_not_found", "viacep_search"} {
+			inner, err := StripJSONP(readFixture(t, name+".jsonp"), "callback")
+			assert.NoError(t, err, name)
+			assert.JSONEq(t, string(readFixture(t, name+".json")), string(inner), name)
+		}
+	})
+
+	t.Run("wrapper variants", func(t *testing.T) {
+		for _, payload := range []string{`cb({"a":1})`, " cb({\"a\":1});\n", `cb({"a":1}) ;`} {
+			inner, err := StripJSONP([]byte(payload), "cb")
+			assert.NoError(t, err, payload)
+			assert.Equal(t, `{"a":1}`, string(inner))
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := StripJSONP(readFixture(t, "viacep_01001000.jsonp"), "other")
+		assert.EqualError(t, err, "malformed jsonp response: expected other(...)")
+
+		_, err = StripJSONP([]byte(`{"cep": "01001-000"}`), "cb")
+		assert.EqualError(t, err, "malformed jsonp response: expected cb(...)")
+
+		_, err = StripJSONP([]byte(`cb({"cep": })`), "cb")
+		assert.EqualError(t, err, "malformed jsonp response: cb(...) does not wrap valid json")
+
+		_, err = StripJSONP([]byte(`cb({});alert(1)`), "cb")
+		assert.Error(t, err)
+	})
+
+	t.Run("unsafe callback", func(t *testing.T) {
+		_, err := StripJSONP([]byte(`alert(1)`), "alert(1)")
+		assert.ErrorIs(t, err, ErrInvalidCallback)
+	})
+}
+
+func TestViaCep_JSONP_DecodeJSONP(t *testing.T) {
+	var address Address
+	assert.NoError(t, DecodeJSONP(readFixture(t, "viacep_01001000.jsonp"), "callback", &address))
+	assert.Equal(t, addressSe, address)
+
+	address = Address{}
+	err := DecodeJSONP(readFixture(t, "viacep_not_found.jsonp"), "callback", &address)
+	assert.ErrorIs(t, err, ErrCepNotFound)
+	assert.Equal(t, Address{}, address)
+
+	var addresses []Address
+	assert.NoError(t, DecodeJSONP(readFixture(t, "viacep_search.jsonp"), "callback", &addresses))
+	assert.Equal(t, addressesDomingos, addresses)
+
+	err = DecodeJSONP(readFixture(t, "viacep_01001000.jsonp"), "callback", &addresses)
+	assert.ErrorContains(t, err, "failed to decode jsonp response")
+
+	err = DecodeJSONP([]byte(`callback([])`), "callback", &address)
+	assert.ErrorContains(t, err, "failed to decode jsonp response")
+
+	err = DecodeJSONP(readFixture(t, "viacep_01001000.jsonp"), "cb", &address)
+	assert.ErrorContains(t, err, "malformed jsonp response")
+}
+
+func TestViaCep_JSONP_ViaCep(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+
+		fixtures := map[string]string{
+			"/ws/01001000/json/":                 "viacep_01001000.jsonp",
+			"/ws/99999999/json/":                 "viacep_not_found.jsonp",
+			"/ws/RS/Porto Alegre/Domingos/json/": "viacep_search.jsonp",
+		}
+
+		name, ok := fixtures[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/javascript")
+		_, _ = w.Write(readFixture(t, name))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+
+	t.Run("cep", func(t *testing.T) {
+		payload, err := c.CepJSONP(ctx, "01001-000", "callback")
+		assert.NoError(t, err)
+		assert.Equal(t, readFixture(t, "viacep_01001000.jsonp"), payload)
+		assert.Equal(t, "callback=callback", queries[len(queries)-1])
+
+		payload, err = c.CepJSONP(ctx, "99999999", "callback")
+		assert.NoError(t, err)
+		assert.Equal(t, readFixture(t, "viacep_not_found.jsonp"), payload)
+	})
+
+	t.Run("addresses", func(t *testing.T) {
+		payload, err := c.AddressesJSONP(ctx, "RS", "Porto Alegre", "Domingos", "callback")
+		assert.NoError(t, err)
+
+		var addresses []Address
+		assert.NoError(t, DecodeJSONP(payload, "callback", &addresses))
+		assert.Equal(t, addressesDomingos, addresses)
+	})
+
+	t.Run("response wrapped in another callback", func(t *testing.T) {
+		payload, err := c.CepJSONP(ctx, "01001000", "app.onCep")
+		assert.Nil(t, payload)
+		assert.EqualError(t, err, "malformed jsonp response: expected app.onCep(...)")
+	})
+
+	t.Run("unsafe callback is not sent", func(t *testing.T) {
+		sent := len(queries)
+
+		payload, err := c.CepJSONP(ctx, "01001000", "alert(document.cookie)")
+		assert.Nil(t, payload)
+		assert.ErrorIs(t, err, ErrInvalidCallback)
+
+		_, err = c.AddressesJSONP(ctx, "RS", "Porto Alegre", "Domingos", "x;y")
+		assert.ErrorIs(t, err, ErrInvalidCallback)
+		assert.Len(t, queries, sent)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		_, err := c.CepJSONP(ctx, "0100", "callback")
+		assert.ErrorIs(t, err, ErrInvalidCEP)
+
+		_, err = c.AddressesJSONP(ctx, "RS", "PA", "Domingos", "callback")
+		assert.ErrorIs(t, err, ErrInvalidSearch)
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		_, err := c.CepJSONP(ctx, "00000000", "callback")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -0,0 +1,109 @@
+package viacep
+
+import (
+	"strings"
+	"time"
+)
+
+const defaultMaxRetry = 1
+
+// Option configures a ViaCep instance created with New.
+type Option func(*ViaCep)
+
+// WithHTTP sets the HTTP client used to reach the API. Defaults to NewHTTPClient(1).
+func WithHTTP(httpClient HTTP) Option {
+	return func(v *ViaCep) {
+		v.httpClient = httpClient
+	}
+}
+
+// WithCache sets the cache used to store lookups, e.g. a *RedisCache. Defaults to an in-memory cache.
+func WithCache(cache Cache) Option {
+	return func(v *ViaCep) {
+		v.cache = cache
+	}
+}
+
+// WithNoCache disables caching, so every lookup reaches the API.
+func WithNoCache() Option {
+	return func(v *ViaCep) {
+		v.cache = noopCache{}
+	}
+}
+
+// WithBaseURL sets the API base URL, e.g. a local mock server. Defaults to https://viacep.com.br.
+func WithBaseURL(baseURL string) Option {
+	return func(v *ViaCep) {
+		v.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithCacheTTL sets how long successful lookups are cached. Defaults to one hour.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(v *ViaCep) {
+		v.cacheTTL = ttl
+	}
+}
+
+// WithNegativeCacheTTL sets how long unknown CEPs are cached, so that repeated lookups return
+// ErrCepNotFound without reaching the API. Defaults to five minutes; a value <= 0 disables
+// negative caching.
+func WithNegativeCacheTTL(ttl time.Duration) Option {
+	return func(v *ViaCep) {
+		v.negativeCacheTTL = ttl
+	}
+}
+
+// WithStaleWhileRevalidate keeps addresses in the cache for window past the cache TTL. A lookup
+// hitting such a stale address returns it immediately and refreshes it in the background.
+func WithStaleWhileRevalidate(window time.Duration) Option {
+	return func(v *ViaCep) {
+		v.staleWhileRevalidate = window
+	}
+}
+
+// WithStaleIfError keeps addresses in the cache for window past the cache TTL. A lookup hitting
+// such a stale address tries the API first and falls back to the stale address if the request
+// fails for any reason other than the caller's context being done. When combined with
+// WithStaleWhileRevalidate, the latter takes precedence within its own window.
+func WithStaleIfError(window time.Duration) Option {
+	return func(v *ViaCep) {
+		v.staleIfError = window
+	}
+}
+
+// WithClock sets the clock used for time-based decisions. Defaults to the system clock.
+func WithClock(clock Clock) Option {
+	return func(v *ViaCep) {
+		v.clock = clock
+	}
+}
+
+// WithRateLimiter paces the requests sent to ViaCEP. Cache hits do not consume permits, while
+// every retry sent by HTTPClient or StdHTTPClient takes one of its own. Share one limiter
+// between several ViaCep instances to apply a single limit to all of them.
+func WithRateLimiter(limiter RateLimiter) Option {
+	return func(v *ViaCep) {
+		v.rateLimiter = limiter
+	}
+}
+
+// WithFormat sets the format Cep and Addresses request from ViaCEP. Defaults to FormatJSON.
+// FormatPiped and FormatQuerty are only served for CEP lookups, so Addresses fails with an
+// error wrapping errors.ErrUnsupported when either is set.
+func WithFormat(format Format) Option {
+	return func(v *ViaCep) {
+		v.format = format
+	}
+}
+
+// WithCacheNamespace sets the namespace prefixed to the cache keys, so that several applications
+// or environments can share one Redis without seeing each other's entries. Defaults to "viacep";
+// an empty namespace keeps the default.
+func WithCacheNamespace(namespace string) Option {
+	return func(v *ViaCep) {
+		if namespace != "" {
+			v.cacheNamespace = namespace
+		}
+	}
+}
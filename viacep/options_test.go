@@ -0,0 +1,140 @@
+package viacep
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViaCep_Options_New(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		c := New()
+		assert.IsType(t, &HTTPClient{}, c.httpClient)
+		assert.IsType(t, &MemoryCache{}, c.cache)
+		assert.Equal(t, urlBase, c.baseURL)
+		assert.Equal(t, cacheTTL, c.cacheTTL)
+		assert.Equal(t, systemClock{}, c.clock)
+		assert.Same(t, c.ownedCache, c.cache)
+		assert.NoError(t, c.Close())
+	})
+
+	t.Run("deprecated constructor", func(t *testing.T) {
+		httpClient := NewHTTPClient(3)
+		c := NewWithHTTP(httpClient)
+		assert.Same(t, httpClient, c.httpClient)
+		assert.IsType(t, &MemoryCache{}, c.cache)
+	})
+}
+
+func TestViaCep_Options_WithHTTP(t *testing.T) {
+	httpClient := NewHTTPClient(0)
+	c := New(WithHTTP(httpClient))
+	assert.Same(t, httpClient, c.httpClient)
+}
+
+func TestViaCep_Options_WithBaseURL(t *testing.T) {
+	c := New(WithBaseURL("http://localhost:8080/"))
+	assert.Equal(t, "http://localhost:8080", c.baseURL)
+}
+
+func TestViaCep_Options_WithNegativeCacheTTL(t *testing.T) {
+	assert.Equal(t, negativeCacheTTL, New().negativeCacheTTL)
+
+	c := New(WithNegativeCacheTTL(time.Second))
+	assert.Equal(t, time.Second, c.negativeCacheTTL)
+}
+
+func TestViaCep_Options_WithStaleWhileRevalidate(t *testing.T) {
+	c := New(WithStaleWhileRevalidate(time.Hour))
+	assert.Equal(t, time.Hour, c.staleWhileRevalidate)
+}
+
+func TestViaCep_Options_WithStaleIfError(t *testing.T) {
+	c := New(WithStaleIfError(time.Hour))
+	assert.Equal(t, time.Hour, c.staleIfError)
+}
+
+func TestViaCep_Options_WithClock(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock))
+	assert.Same(t, clock, c.clock)
+}
+
+func TestViaCep_Options_WithCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"cep": "01001-000"}`))
+	}))
+	defer srv.Close()
+
+	client, mock := redismock.NewClientMock()
+	clock := newFakeClock()
+
+	key := cacheKey("01001000")
+	entry := cepEntry{Version: CacheSchemaVersion, Address: Address{Cep: "01001-000"}, StoredAt: clock.Now()}
+	mock.ExpectGet(key).RedisNil()
+	mock.ExpectSet(key, encodeGob(t, entry), 10*time.Minute).SetVal("OK")
+
+	c := New(
+		WithHTTP(NewHTTPClient(0)),
+		WithBaseURL(srv.URL),
+		WithCache(NewRedisCache(client)),
+		WithCacheTTL(10*time.Minute),
+		WithClock(clock),
+	)
+
+	address, err := c.Cep(context.Background(), "01001-000")
+	assert.NoError(t, err)
+	assert.Equal(t, &Address{Cep: "01001-000"}, address)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Nil(t, c.ownedCache)
+	assert.NoError(t, c.Close())
+}
+
+func TestViaCep_Options_WithNoCache(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"cep": "01001-000"}`))
+	}))
+	defer srv.Close()
+
+	c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+	assert.Equal(t, noopCache{}, c.cache)
+
+	for range 2 {
+		_, err := c.Cep(context.Background(), "01001000")
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, int32(2), hits.Load())
+}
+
+func TestViaCep_Options_WithCacheNamespace(t *testing.T) {
+	t.Run("prefixes the cache keys", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithCacheNamespace("tenant-a"))
+		defer c.Close()
+
+		_, err := c.Cep(context.Background(), "01001000")
+		assert.NoError(t, err)
+
+		var entry cepEntry
+		assert.True(t, c.cache.Get(context.Background(), versionedCacheKey("tenant-a", CacheSchemaVersion, "01001000"), &entry))
+		assert.False(t, c.cache.Get(context.Background(), cacheKey("01001000"), &entry))
+	})
+
+	t.Run("empty namespace keeps the default", func(t *testing.T) {
+		c := New(WithCacheNamespace(""))
+		defer c.Close()
+		assert.Equal(t, defaultCacheNamespace, c.cacheNamespace)
+	})
+}
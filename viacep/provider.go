@@ -0,0 +1,205 @@
+package viacep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	brasilAPIURLBase  = "https://brasilapi.com.br"
+	awesomeAPIURLBase = "https://cep.awesomeapi.com.br"
+	openCEPURLBase    = "https://opencep.com"
+)
+
+// Provider is a source of address data for a CEP.
+type Provider interface {
+	// Name returns a short identifier of the provider, used in error messages.
+	Name() string
+
+	// Cep retrieves the address information for a given CEP (postal code).
+	//
+	// Parameters:
+	//   - ctx: The context to manage the request lifecycle, such as timeouts or cancellations.
+	//   - cep: The postal code (CEP) for which the address information will be retrieved.
+	//
+	// Returns:
+	//   - *Address: A pointer to the Address object with the address data.
+	//   - error: ErrInvalidCEP if the CEP is malformed, ErrCepNotFound if it does not exist, or any
+	//            other error that occurs during the request. Otherwise, nil will be returned.
+	Cep(ctx context.Context, cep string) (*Address, error)
+}
+
+// ProviderOption configures the providers created with NewBrasilAPI, NewAwesomeAPI and NewOpenCEP.
+type ProviderOption func(*providerConfig)
+
+type providerConfig struct {
+	httpClient HTTP
+	baseURL    string
+}
+
+// WithProviderHTTP sets the HTTP client used to reach the provider. Defaults to NewHTTPClient(1).
+func WithProviderHTTP(httpClient HTTP) ProviderOption {
+	return func(c *providerConfig) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithProviderBaseURL sets the provider base URL, e.g. a local mock server.
+func WithProviderBaseURL(baseURL string) ProviderOption {
+	return func(c *providerConfig) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+func newProviderConfig(baseURL string, opts []ProviderOption) providerConfig {
+	c := providerConfig{baseURL: baseURL}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if c.httpClient == nil {
+		c.httpClient = NewHTTPClient(defaultMaxRetry)
+	}
+
+	return c
+}
+
+// get validates cep and fetches {baseURL}{path} into dest, mapping HTTP 404 to ErrCepNotFound.
+func (c providerConfig) get(ctx context.Context, cep, path string, dest any) (CEP, error) {
+	parsed, err := ParseCEP(cep)
+	if err != nil {
+		return "", err
+	}
+
+	url := c.baseURL + fmt.Sprintf(path, parsed)
+	if err := c.httpClient.Get(ctx, url, dest); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return "", fmt.Errorf("%w: %s", ErrCepNotFound, parsed)
+		}
+
+		return "", err
+	}
+
+	return parsed, nil
+}
+
+// BrasilAPI is a Provider backed by the BrasilAPI CEP v2 endpoint.
+type BrasilAPI struct {
+	config providerConfig
+}
+
+type brasilAPIResponse struct {
+	State        string `json:"state"`
+	City         string `json:"city"`
+	Neighborhood string `json:"neighborhood"`
+	Street       string `json:"street"`
+}
+
+// AwesomeAPI is a Provider backed by the AwesomeAPI CEP endpoint.
+type AwesomeAPI struct {
+	config providerConfig
+}
+
+type awesomeAPIResponse struct {
+	Address  string `json:"address"`
+	State    string `json:"state"`
+	District string `json:"district"`
+	City     string `json:"city"`
+	CityIbge string `json:"city_ibge"`
+	Ddd      string `json:"ddd"`
+}
+
+// OpenCEP is a Provider backed by the OpenCEP endpoint.
+type OpenCEP struct {
+	config providerConfig
+}
+
+func NewBrasilAPI(opts ...ProviderOption) *BrasilAPI {
+	return &BrasilAPI{config: newProviderConfig(brasilAPIURLBase, opts)}
+}
+
+func NewAwesomeAPI(opts ...ProviderOption) *AwesomeAPI {
+	return &AwesomeAPI{config: newProviderConfig(awesomeAPIURLBase, opts)}
+}
+
+func NewOpenCEP(opts ...ProviderOption) *OpenCEP {
+	return &OpenCEP{config: newProviderConfig(openCEPURLBase, opts)}
+}
+
+func (v *ViaCep) Name() string {
+	return "viacep"
+}
+
+func (b *BrasilAPI) Name() string {
+	return "brasilapi"
+}
+
+func (b *BrasilAPI) Cep(ctx context.Context, cep string) (*Address, error) {
+	var resp brasilAPIResponse
+	parsed, err := b.config.get(ctx, cep, "/api/cep/v2/%s", &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return withFederativeUnit(&Address{
+		Cep:        parsed.Formatted(),
+		Logradouro: resp.Street,
+		Bairro:     resp.Neighborhood,
+		Localidade: resp.City,
+		Uf:         resp.State,
+	}), nil
+}
+
+func (a *AwesomeAPI) Name() string {
+	return "awesomeapi"
+}
+
+func (a *AwesomeAPI) Cep(ctx context.Context, cep string) (*Address, error) {
+	var resp awesomeAPIResponse
+	parsed, err := a.config.get(ctx, cep, "/json/%s", &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return withFederativeUnit(&Address{
+		Cep:        parsed.Formatted(),
+		Logradouro: resp.Address,
+		Bairro:     resp.District,
+		Localidade: resp.City,
+		Uf:         resp.State,
+		Ibge:       resp.CityIbge,
+		Ddd:        resp.Ddd,
+	}), nil
+}
+
+func (o *OpenCEP) Name() string {
+	return "opencep"
+}
+
+func (o *OpenCEP) Cep(ctx context.Context, cep string) (*Address, error) {
+	var resp Address
+	parsed, err := o.config.get(ctx, cep, "/v1/%s", &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Cep = parsed.Formatted()
+	return withFederativeUnit(&resp), nil
+}
+
+// withFederativeUnit fills Estado and Regiao from Uf when the provider does not return them.
+func withFederativeUnit(address *Address) *Address {
+	if unit, ok := federativeUnits[address.Uf]; ok {
+		if address.Estado == "" {
+			address.Estado = unit.estado
+		}
+
+		if address.Regiao == "" {
+			address.Regiao = unit.regiao
+		}
+	}
+
+	return address
+}
@@ -0,0 +1,138 @@
+package viacep
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fixture struct {
+	status int
+	file   string
+}
+
+// newFixtureServer serves recorded payloads from testdata, keyed by request path.
+func newFixtureServer(t *testing.T, routes map[string]fixture) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := routes[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		payload, err := os.ReadFile(filepath.Join("testdata", route.file))
+		if err != nil {
+			t.Errorf("failed to read fixture %s: %v", route.file, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(route.status)
+		_, _ = w.Write(payload)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestViaCep_Provider_Name(t *testing.T) {
+	assert.Equal(t, "viacep", New(WithNoCache()).Name())
+	assert.Equal(t, "brasilapi", NewBrasilAPI().Name())
+	assert.Equal(t, "awesomeapi", NewAwesomeAPI().Name())
+	assert.Equal(t, "opencep", NewOpenCEP().Name())
+}
+
+func TestViaCep_Provider_Defaults(t *testing.T) {
+	assert.Equal(t, brasilAPIURLBase, NewBrasilAPI().config.baseURL)
+	assert.Equal(t, awesomeAPIURLBase, NewAwesomeAPI().config.baseURL)
+	assert.Equal(t, openCEPURLBase, NewOpenCEP().config.baseURL)
+	assert.IsType(t, &HTTPClient{}, NewOpenCEP().config.httpClient)
+
+	httpClient := NewHTTPClient(0)
+	provider := NewOpenCEP(WithProviderHTTP(httpClient), WithProviderBaseURL("http://localhost/"))
+	assert.Same(t, httpClient, provider.config.httpClient)
+	assert.Equal(t, "http://localhost", provider.config.baseURL)
+}
+
+func TestViaCep_Provider_Cep(t *testing.T) {
+	srv := newFixtureServer(t, map[string]fixture{
+		"/api/cep/v2/01001000": {http.StatusOK, "brasilapi_01001000.json"},
+		"/api/cep/v2/99999999": {http.StatusNotFound, "brasilapi_not_found.json"},
+		"/json/01001000":       {http.StatusOK, "awesomeapi_01001000.json"},
+		"/json/99999999":       {http.StatusNotFound, "awesomeapi_not_found.json"},
+		"/v1/01001000":         {http.StatusOK, "opencep_01001000.json"},
+		"/v1/99999999":         {http.StatusNotFound, "opencep_not_found.json"},
+		"/v1/00000000":         {http.StatusBadGateway, "opencep_not_found.json"},
+	})
+
+	opts := []ProviderOption{WithProviderHTTP(NewHTTPClient(0)), WithProviderBaseURL(srv.URL)}
+
+	testCases := []struct {
+		provider Provider
+		expected Address
+	}{
+		{
+			provider: NewBrasilAPI(opts...),
+			expected: Address{
+				Cep: "01001-000", Logradouro: "Praça da Sé", Bairro: "Sé", Localidade: "São Paulo", Uf: "SP",
+				Estado: "São Paulo", Regiao: "Sudeste",
+			},
+		},
+		{
+			provider: NewAwesomeAPI(opts...),
+			expected: Address{
+				Cep: "01001-000", Logradouro: "Praça da Sé", Bairro: "Sé", Localidade: "São Paulo", Uf: "SP",
+				Estado: "São Paulo", Regiao: "Sudeste", Ibge: "3550308", Ddd: "11",
+			},
+		},
+		{
+			provider: NewOpenCEP(opts...),
+			expected: Address{
+				Cep: "01001-000", Logradouro: "Praça da Sé", Complemento: "lado ímpar", Bairro: "Sé",
+				Localidade: "São Paulo", Uf: "SP", Estado: "São Paulo", Regiao: "Sudeste", Ibge: "3550308",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.provider.Name(), func(t *testing.T) {
+			address, err := tc.provider.Cep(context.Background(), "01001-000")
+			assert.NoError(t, err)
+			assert.Equal(t, &tc.expected, address)
+
+			address, err = tc.provider.Cep(context.Background(), "99999999")
+			assert.Nil(t, address)
+			assert.ErrorIs(t, err, ErrCepNotFound)
+			assert.EqualError(t, err, "cep not found: 99999999")
+
+			address, err = tc.provider.Cep(context.Background(), "0100100a")
+			assert.Nil(t, address)
+			assert.ErrorIs(t, err, ErrInvalidCEP)
+		})
+	}
+
+	t.Run("upstream error", func(t *testing.T) {
+		address, err := NewOpenCEP(opts...).Cep(context.Background(), "00000000")
+		assert.Nil(t, address)
+		assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+	})
+}
+
+func TestViaCep_Provider_withFederativeUnit(t *testing.T) {
+	address := withFederativeUnit(&Address{Uf: "RS"})
+	assert.Equal(t, &Address{Uf: "RS", Estado: "Rio Grande do Sul", Regiao: "Sul"}, address)
+
+	address = withFederativeUnit(&Address{Uf: "RS", Estado: "RS", Regiao: "S"})
+	assert.Equal(t, &Address{Uf: "RS", Estado: "RS", Regiao: "S"}, address)
+
+	address = withFederativeUnit(&Address{Uf: "XX"})
+	assert.Equal(t, &Address{Uf: "XX"}, address)
+}
@@ -0,0 +1,233 @@
+package viacep
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const rateLimitPrefix = defaultCacheNamespace + ":ratelimit:"
+
+// RateLimiter paces requests sent to an API.
+type RateLimiter interface {
+	// Wait blocks until a request may be sent.
+	//
+	// Parameters:
+	//   - ctx: The context of the request. Wait returns early when it is done and fails
+	//          immediately when the required wait would go past its deadline.
+	//
+	// Returns:
+	//   - error: The context error if the request may not be sent in time, or any other error
+	//            that prevents acquiring a permit. Otherwise, nil will be returned.
+	//
+	// Example:
+	//   limiter := viacep.NewTokenBucket(5, 10)
+	//   client := viacep.New(viacep.WithRateLimiter(limiter))
+	Wait(ctx context.Context) error
+}
+
+// TokenBucket is an in-process RateLimiter allowing rps requests per second on average with
+// bursts of up to burst requests. It is safe for concurrent use, so a single TokenBucket can be
+// shared by several ViaCep instances to enforce one limit for the whole process.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  Clock
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// TokenBucketOption configures a TokenBucket created with NewTokenBucket.
+type TokenBucketOption func(*TokenBucket)
+
+// RedisRateLimiter is a RateLimiter that keeps its token bucket in Redis, so that every process
+// using the same key shares one limit. Refills are computed from the Redis server clock, which
+// keeps the limit accurate even when the clocks of the processes drift apart.
+type RedisRateLimiter struct {
+	client *redis.Client
+	key    string
+	rate   float64
+	burst  int
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// tokenBucketLua takes one token from the bucket stored at KEYS[1] and returns 0, or returns
+// how many microseconds to wait before a token is available without taking it.
+const tokenBucketLua = `
+redis.replicate_commands()
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(state[1]) or burst
+local updated = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updated) * rate / 1000000)
+if tokens < 1 then
+  return math.ceil((1 - tokens) * 1000000 / rate)
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens - 1), "updated", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return 0
+`
+
+var tokenBucketScript = redis.NewScript(tokenBucketLua)
+
+// WithTokenBucketClock sets the clock used to refill the bucket. Defaults to the system clock.
+func WithTokenBucketClock(clock Clock) TokenBucketOption {
+	return func(b *TokenBucket) {
+		b.clock = clock
+	}
+}
+
+// NewTokenBucket creates a TokenBucket that starts full. A burst below 1 is treated as 1 and
+// an rps <= 0 disables the limit.
+func NewTokenBucket(rps float64, burst int, opts ...TokenBucketOption) *TokenBucket {
+	b := &TokenBucket{
+		rate:  rps,
+		burst: float64(max(burst, 1)),
+		clock: systemClock{},
+		sleep: sleepContext,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	b.tokens = b.burst
+	b.last = b.clock.Now()
+	return b
+}
+
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if b.rate <= 0 {
+		return nil
+	}
+
+	delay := b.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	if err := checkDeadline(ctx, delay); err != nil {
+		b.release()
+		return err
+	}
+
+	if err := b.sleep(ctx, delay); err != nil {
+		b.release()
+		return err
+	}
+
+	return nil
+}
+
+// reserve takes a token, letting the bucket go negative, and returns how long the caller must
+// wait for that token to be refilled.
+func (b *TokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(math.Ceil(-b.tokens / b.rate * float64(time.Second)))
+}
+
+// release gives back a token taken by reserve for a request that will not be sent.
+func (b *TokenBucket) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+// refill must be called with b.mu held.
+func (b *TokenBucket) refill() {
+	now := b.clock.Now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+	}
+
+	b.last = now
+}
+
+// NewRedisRateLimiter creates a RedisRateLimiter allowing rps requests per second with bursts of
+// up to burst requests, shared by every RedisRateLimiter created with the same name.
+func NewRedisRateLimiter(client *redis.Client, name string, rps float64, burst int) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client: client,
+		key:    rateLimitPrefix + name,
+		rate:   rps,
+		burst:  max(burst, 1),
+		sleep:  sleepContext,
+	}
+}
+
+// Wait polls the shared bucket until a token is taken. Unlike the TokenBucket it does not
+// queue callers, so waiting processes compete for each refilled token. Redis errors are returned
+// rather than ignored, so an unreachable Redis stops requests instead of lifting the limit.
+func (r *RedisRateLimiter) Wait(ctx context.Context) error {
+	if r.rate <= 0 {
+		return ctx.Err()
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		wait, err := tokenBucketScript.Run(ctx, r.client, []string{r.key}, r.rate, r.burst).Int64()
+		if err != nil {
+			return fmt.Errorf("failed to take rate limit token: %w", err)
+		}
+
+		if wait <= 0 {
+			return nil
+		}
+
+		delay := time.Duration(wait) * time.Microsecond
+		if err := checkDeadline(ctx, delay); err != nil {
+			return err
+		}
+
+		if err := r.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// checkDeadline fails when waiting delay would go past the deadline of ctx.
+func checkDeadline(ctx context.Context, delay time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return fmt.Errorf("rate limit wait of %s exceeds context deadline: %w", delay, context.DeadlineExceeded)
+	}
+
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,251 @@
+package viacep
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSleeper records requested sleeps and advances clock by them instead of blocking.
+type fakeSleeper struct {
+	mu     sync.Mutex
+	clock  *fakeClock
+	sleeps []time.Duration
+}
+
+func (s *fakeSleeper) sleep(ctx context.Context, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.sleeps = append(s.sleeps, d)
+	if s.clock != nil {
+		s.clock.Advance(d)
+	}
+
+	return nil
+}
+
+func TestViaCep_TokenBucket_Wait(t *testing.T) {
+	ctx := context.Background()
+
+	newBucket := func(rps float64, burst int) (*TokenBucket, *fakeClock, *fakeSleeper) {
+		clock := newFakeClock()
+		sleeper := &fakeSleeper{clock: clock}
+		bucket := NewTokenBucket(rps, burst, WithTokenBucketClock(clock))
+		bucket.sleep = sleeper.sleep
+		return bucket, clock, sleeper
+	}
+
+	t.Run("burst passes without waiting", func(t *testing.T) {
+		bucket, _, sleeper := newBucket(2, 3)
+
+		for range 3 {
+			assert.NoError(t, bucket.Wait(ctx))
+		}
+
+		assert.Empty(t, sleeper.sleeps)
+	})
+
+	t.Run("waits for refill once the burst is spent", func(t *testing.T) {
+		bucket, _, sleeper := newBucket(2, 1)
+
+		for range 3 {
+			assert.NoError(t, bucket.Wait(ctx))
+		}
+
+		assert.Equal(t, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}, sleeper.sleeps)
+	})
+
+	t.Run("refills over time up to the burst", func(t *testing.T) {
+		bucket, clock, sleeper := newBucket(1, 2)
+
+		assert.NoError(t, bucket.Wait(ctx))
+		assert.NoError(t, bucket.Wait(ctx))
+		clock.Advance(time.Hour)
+		assert.NoError(t, bucket.Wait(ctx))
+		assert.NoError(t, bucket.Wait(ctx))
+		assert.NoError(t, bucket.Wait(ctx))
+
+		assert.Equal(t, []time.Duration{time.Second}, sleeper.sleeps)
+	})
+
+	t.Run("fails fast when the wait exceeds the deadline", func(t *testing.T) {
+		bucket, _, sleeper := newBucket(0.1, 1)
+		assert.NoError(t, bucket.Wait(ctx))
+
+		deadlineCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+
+		err := bucket.Wait(deadlineCtx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.EqualError(t, err, "rate limit wait of 10s exceeds context deadline: context deadline exceeded")
+		assert.Empty(t, sleeper.sleeps)
+		assert.InDelta(t, 0, bucket.tokens, 1e-9, "the reserved token is given back")
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		bucket, _, _ := newBucket(1, 1)
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		assert.ErrorIs(t, bucket.Wait(canceled), context.Canceled)
+		assert.InDelta(t, 1, bucket.tokens, 1e-9)
+	})
+
+	t.Run("context canceled while waiting", func(t *testing.T) {
+		bucket := NewTokenBucket(0.001, 1)
+		assert.NoError(t, bucket.Wait(ctx))
+
+		canceled, cancel := context.WithCancel(ctx)
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		assert.ErrorIs(t, bucket.Wait(canceled), context.Canceled)
+	})
+
+	t.Run("zero rate disables the limit", func(t *testing.T) {
+		bucket, _, sleeper := newBucket(0, 1)
+
+		for range 10 {
+			assert.NoError(t, bucket.Wait(ctx))
+		}
+
+		assert.Empty(t, sleeper.sleeps)
+	})
+
+	t.Run("shared between viacep instances", func(t *testing.T) {
+		var hits atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"cep":"01001-000"}`))
+		}))
+		defer srv.Close()
+
+		bucket, _, sleeper := newBucket(1, 1)
+		first := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithRateLimiter(bucket))
+		second := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithRateLimiter(bucket))
+		defer first.Close()
+		defer second.Close()
+
+		_, err := first.Cep(ctx, "01001000")
+		assert.NoError(t, err)
+		_, err = second.Cep(ctx, "01001000")
+		assert.NoError(t, err)
+		_, err = second.Cep(ctx, "01310100")
+		assert.NoError(t, err)
+
+		_, err = first.Cep(ctx, "01001000")
+		assert.NoError(t, err, "cache hits do not consume permits")
+
+		assert.Equal(t, int32(3), hits.Load())
+		assert.Equal(t, []time.Duration{time.Second, time.Second}, sleeper.sleeps)
+	})
+
+	t.Run("retries consume permits", func(t *testing.T) {
+		for name, newClient := range map[string]func(opts ...HTTPOption) HTTP{
+			"resty":    func(opts ...HTTPOption) HTTP { return NewHTTPClient(2, opts...) },
+			"net/http": func(opts ...HTTPOption) HTTP { return NewStdHTTPClient(2, opts...) },
+		} {
+			t.Run(name, func(t *testing.T) {
+				srv, hits := newFlakyServer(t, 2, http.StatusServiceUnavailable)
+
+				bucket, _, sleeper := newBucket(1, 1)
+				retry := WithRetryPolicy(RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond})
+				c := New(WithHTTP(NewCircuitBreaker(newClient(retry))), WithBaseURL(srv.URL), WithRateLimiter(bucket), WithNoCache())
+
+				_, err := c.Cep(ctx, "01001000")
+				assert.NoError(t, err)
+				assert.Equal(t, int32(3), hits.Load())
+				assert.Equal(t, []time.Duration{time.Second, time.Second}, sleeper.sleeps)
+			})
+		}
+	})
+}
+
+func TestViaCep_RedisRateLimiter_Wait(t *testing.T) {
+	ctx := context.Background()
+	key := "viacep:ratelimit:fleet"
+	sha := tokenBucketScript.Hash()
+
+	t.Run("token available", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		limiter := NewRedisRateLimiter(client, "fleet", 5, 10)
+
+		mock.ExpectEvalSha(sha, []string{key}, 5.0, 10).SetVal(int64(0))
+
+		assert.NoError(t, limiter.Wait(ctx))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("waits and retries", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		limiter := NewRedisRateLimiter(client, "fleet", 5, 10)
+		sleeper := &fakeSleeper{}
+		limiter.sleep = sleeper.sleep
+
+		mock.ExpectEvalSha(sha, []string{key}, 5.0, 10).SetVal(int64(200_000))
+		mock.ExpectEvalSha(sha, []string{key}, 5.0, 10).SetVal(int64(0))
+
+		assert.NoError(t, limiter.Wait(ctx))
+		assert.Equal(t, []time.Duration{200 * time.Millisecond}, sleeper.sleeps)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("loads the script when missing", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		limiter := NewRedisRateLimiter(client, "fleet", 5, 10)
+
+		mock.ExpectEvalSha(sha, []string{key}, 5.0, 10).SetErr(errors.New("NOSCRIPT No matching script"))
+		mock.ExpectEval(tokenBucketLua, []string{key}, 5.0, 10).SetVal(int64(0))
+
+		assert.NoError(t, limiter.Wait(ctx))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("fails fast when the wait exceeds the deadline", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		limiter := NewRedisRateLimiter(client, "fleet", 5, 10)
+
+		deadlineCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+
+		mock.ExpectEvalSha(sha, []string{key}, 5.0, 10).SetVal(int64(5_000_000))
+
+		assert.ErrorIs(t, limiter.Wait(deadlineCtx), context.DeadlineExceeded)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("redis error", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		limiter := NewRedisRateLimiter(client, "fleet", 5, 10)
+
+		mock.ExpectEvalSha(sha, []string{key}, 5.0, 10).SetErr(errors.New("connection refused"))
+
+		assert.EqualError(t, limiter.Wait(ctx), "failed to take rate limit token: connection refused")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		limiter := NewRedisRateLimiter(client, "fleet", 5, 10)
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		assert.ErrorIs(t, limiter.Wait(canceled), context.Canceled)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,184 @@
+package viacep
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const defaultMaxRetryDelay = 10 * time.Second
+
+// RetryPolicy decides which failed requests are sent again and how long to wait in between.
+//
+// Only network errors and the 429, 502, 503 and 504 status codes are retried. The wait before
+// retry n is drawn uniformly from [0, min(MaxDelay, BaseDelay*2^(n-1))] ("full jitter"), unless
+// the API sent a Retry-After header, in which case exactly that long is waited. Retrying stops
+// early when the wait would go past the context deadline or a Retry-After exceeds MaxDelay.
+type RetryPolicy struct {
+	// MaxRetries is the number of requests sent after the first one fails. 0 disables retries.
+	MaxRetries int
+	// BaseDelay is the upper bound of the wait before the first retry; it doubles on each retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the wait before any retry.
+	MaxDelay time.Duration
+}
+
+// attempt is the outcome of a single request.
+type attempt struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	err        error
+}
+
+// retrier runs requests under a RetryPolicy; sleep and jitter are replaced in tests.
+type retrier struct {
+	policy RetryPolicy
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func(d time.Duration) time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used by NewHTTPClient: maxRetries retries starting at
+// 500ms and capped at 10s.
+func DefaultRetryPolicy(maxRetries int) RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: maxRetries,
+		BaseDelay:  retryWaitTime,
+		MaxDelay:   defaultMaxRetryDelay,
+	}
+}
+
+func newRetrier(policy RetryPolicy) retrier {
+	return retrier{policy: policy, sleep: sleepContext, jitter: fullJitter}
+}
+
+// run calls send until it succeeds, fails with an error that is not retryable or runs out of
+// retries, and returns an *APIError describing the last attempt.
+func (r retrier) run(ctx context.Context, url string, send func(ctx context.Context) attempt) error {
+	for attempts := 1; ; attempts++ {
+		result := send(ctx)
+		if result.err == nil && result.statusCode == http.StatusOK {
+			return nil
+		}
+
+		apiErr := newAPIError(url, result.statusCode, result.body, attempts, result.err)
+		if attempts > r.policy.MaxRetries || ctx.Err() != nil || !isRetryable(result) {
+			return apiErr
+		}
+
+		if !r.backoff(ctx, attempts, result.header) {
+			return apiErr
+		}
+	}
+}
+
+// backoff waits before the given retry (starting at 1), then for a permit of the limiter set
+// with withRetryLimiter, if any, and reports whether the retry may be sent.
+func (r retrier) backoff(ctx context.Context, retry int, header http.Header) bool {
+	delay, ok := r.policy.delay(retry, header, r.jitter)
+	if !ok || checkDeadline(ctx, delay) != nil {
+		return false
+	}
+
+	if err := r.sleep(ctx, delay); err != nil {
+		return false
+	}
+
+	limiter, ok := ctx.Value(retryLimiterKey{}).(RateLimiter)
+	return !ok || limiter.Wait(ctx) == nil
+}
+
+// retryLimiterKey is the context key of the RateLimiter retries wait on.
+type retryLimiterKey struct{}
+
+// withRetryLimiter returns a copy of ctx under which every retry sent by HTTPClient and
+// StdHTTPClient first waits for a permit of limiter.
+func withRetryLimiter(ctx context.Context, limiter RateLimiter) context.Context {
+	return context.WithValue(ctx, retryLimiterKey{}, limiter)
+}
+
+// delay returns the wait before the given retry (starting at 1), or false when the API asks to
+// wait longer than MaxDelay.
+func (p RetryPolicy) delay(retry int, header http.Header, jitter func(time.Duration) time.Duration) (time.Duration, bool) {
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxRetryDelay
+	}
+
+	if after, ok := parseRetryAfter(header.Get("Retry-After"), time.Now()); ok {
+		return after, after <= maxDelay
+	}
+
+	backoff := maxDelay
+	if shift := retry - 1; shift < 32 && p.BaseDelay < maxDelay>>shift {
+		backoff = p.BaseDelay << shift
+	}
+
+	return jitter(backoff), true
+}
+
+// isRetryable reports whether a failed attempt may succeed if sent again.
+func isRetryable(result attempt) bool {
+	if result.err != nil {
+		return isNetworkError(result.err)
+	}
+
+	switch result.statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isNetworkError reports whether err comes from the connection rather than from building the
+// request or decoding the response.
+func isNetworkError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	// *url.Error implements net.Error itself, so look at the error it wraps.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+func fullJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	return rand.N(d + 1)
+}
@@ -0,0 +1,185 @@
+package viacep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViaCep_RetryPolicy_delay(t *testing.T) {
+	noJitter := func(d time.Duration) time.Duration { return d }
+	policy := RetryPolicy{MaxRetries: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	t.Run("exponential backoff capped at max delay", func(t *testing.T) {
+		var delays []time.Duration
+		for retry := 1; retry <= 6; retry++ {
+			delay, ok := policy.delay(retry, http.Header{}, noJitter)
+			assert.True(t, ok)
+			delays = append(delays, delay)
+		}
+
+		assert.Equal(t, []time.Duration{
+			100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond,
+			time.Second, time.Second,
+		}, delays)
+
+		delay, ok := policy.delay(100, http.Header{}, noJitter)
+		assert.True(t, ok)
+		assert.Equal(t, time.Second, delay)
+	})
+
+	t.Run("full jitter", func(t *testing.T) {
+		for range 100 {
+			delay, ok := policy.delay(3, http.Header{}, fullJitter)
+			assert.True(t, ok)
+			assert.GreaterOrEqual(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, 400*time.Millisecond)
+		}
+	})
+
+	t.Run("retry-after", func(t *testing.T) {
+		delay, ok := policy.delay(1, http.Header{"Retry-After": {"1"}}, noJitter)
+		assert.True(t, ok)
+		assert.Equal(t, time.Second, delay)
+
+		_, ok = policy.delay(1, http.Header{"Retry-After": {"120"}}, noJitter)
+		assert.False(t, ok, "longer than max delay")
+	})
+
+	t.Run("default max delay", func(t *testing.T) {
+		delay, ok := RetryPolicy{BaseDelay: time.Hour}.delay(1, http.Header{}, noJitter)
+		assert.True(t, ok)
+		assert.Equal(t, defaultMaxRetryDelay, delay)
+	})
+}
+
+func TestViaCep_RetryPolicy_DefaultRetryPolicy(t *testing.T) {
+	assert.Equal(t, RetryPolicy{MaxRetries: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 10 * time.Second}, DefaultRetryPolicy(3))
+	assert.Equal(t, DefaultRetryPolicy(2), NewHTTPClient(2).retrier.policy)
+}
+
+func TestViaCep_RetryPolicy_isRetryable(t *testing.T) {
+	testCases := []struct {
+		name     string
+		result   attempt
+		expected bool
+	}{
+		{"429", attempt{statusCode: http.StatusTooManyRequests}, true},
+		{"502", attempt{statusCode: http.StatusBadGateway}, true},
+		{"503", attempt{statusCode: http.StatusServiceUnavailable}, true},
+		{"504", attempt{statusCode: http.StatusGatewayTimeout}, true},
+		{"400", attempt{statusCode: http.StatusBadRequest}, false},
+		{"404", attempt{statusCode: http.StatusNotFound}, false},
+		{"500", attempt{statusCode: http.StatusInternalServerError}, false},
+		{"connection refused", attempt{err: &url.Error{Op: "Get", URL: "http://localhost", Err: &net.OpError{
+			Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED,
+		}}}, true},
+		{"unexpected eof", attempt{err: &url.Error{Op: "Get", URL: "http://localhost", Err: io.ErrUnexpectedEOF}}, true},
+		{"unsupported scheme", attempt{err: &url.Error{Op: "Get", URL: "x://", Err: errors.New("unsupported protocol scheme")}}, false},
+		{"canceled", attempt{err: &url.Error{Op: "Get", URL: "http://localhost", Err: context.Canceled}}, false},
+		{"deadline", attempt{err: fmt.Errorf("get: %w", context.DeadlineExceeded)}, false},
+		{"decode", attempt{err: errors.New("invalid character")}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isRetryable(tc.result))
+		})
+	}
+}
+
+func TestViaCep_RetryPolicy_parseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.November, 29, 10, 0, 0, 0, time.UTC)
+
+	delay, ok := parseRetryAfter("30", now)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, delay)
+
+	delay, ok = parseRetryAfter("Fri, 29 Nov 2024 10:01:00 GMT", now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, delay)
+
+	delay, ok = parseRetryAfter("Fri, 29 Nov 2024 09:00:00 GMT", now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), delay)
+
+	_, ok = parseRetryAfter("", now)
+	assert.False(t, ok)
+
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(t, ok)
+}
+
+func TestViaCep_RetryPolicy_run(t *testing.T) {
+	t.Run("stops when the context is canceled while waiting", func(t *testing.T) {
+		r := newRetrier(RetryPolicy{MaxRetries: 5, BaseDelay: time.Millisecond})
+		r.sleep = func(context.Context, time.Duration) error { return context.Canceled }
+
+		calls := 0
+		err := r.run(context.Background(), "http://localhost", func(context.Context) attempt {
+			calls++
+			return attempt{statusCode: http.StatusBadGateway}
+		})
+
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 1, apiErr.Attempts)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("does not retry once the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := newRetrier(RetryPolicy{MaxRetries: 5, BaseDelay: time.Millisecond})
+
+		calls := 0
+		err := r.run(ctx, "http://localhost", func(context.Context) attempt {
+			calls++
+			cancel()
+			return attempt{statusCode: http.StatusBadGateway}
+		})
+
+		assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("retries wait for the rate limiter", func(t *testing.T) {
+		r := newRetrier(RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond})
+		limiter := &countingLimiter{}
+
+		calls := 0
+		err := r.run(withRetryLimiter(context.Background(), limiter), "http://localhost", func(context.Context) attempt {
+			calls++
+			return attempt{statusCode: http.StatusServiceUnavailable}
+		})
+
+		assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, int32(2), limiter.permits.Load())
+	})
+
+	t.Run("stops when the rate limiter fails", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := newRetrier(RetryPolicy{MaxRetries: 5, BaseDelay: time.Millisecond})
+
+		calls := 0
+		err := r.run(withRetryLimiter(ctx, cancelingLimiter{cancel: cancel}), "http://localhost", func(context.Context) attempt {
+			calls++
+			return attempt{statusCode: http.StatusBadGateway}
+		})
+
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 1, apiErr.Attempts)
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,78 @@
+package viacep
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// minSearchLength is the minimum number of characters ViaCEP accepts for the city and street.
+const minSearchLength = 3
+
+type federativeUnit struct {
+	estado string
+	regiao string
+}
+
+var federativeUnits = map[string]federativeUnit{
+	"AC": {"Acre", "Norte"},
+	"AL": {"Alagoas", "Nordeste"},
+	"AM": {"Amazonas", "Norte"},
+	"AP": {"Amapá", "Norte"},
+	"BA": {"Bahia", "Nordeste"},
+	"CE": {"Ceará", "Nordeste"},
+	"DF": {"Distrito Federal", "Centro-Oeste"},
+	"ES": {"Espírito Santo", "Sudeste"},
+	"GO": {"Goiás", "Centro-Oeste"},
+	"MA": {"Maranhão", "Nordeste"},
+	"MG": {"Minas Gerais", "Sudeste"},
+	"MS": {"Mato Grosso do Sul", "Centro-Oeste"},
+	"MT": {"Mato Grosso", "Centro-Oeste"},
+	"PA": {"Pará", "Norte"},
+	"PB": {"Paraíba", "Nordeste"},
+	"PE": {"Pernambuco", "Nordeste"},
+	"PI": {"Piauí", "Nordeste"},
+	"PR": {"Paraná", "Sul"},
+	"RJ": {"Rio de Janeiro", "Sudeste"},
+	"RN": {"Rio Grande do Norte", "Nordeste"},
+	"RO": {"Rondônia", "Norte"},
+	"RR": {"Roraima", "Norte"},
+	"RS": {"Rio Grande do Sul", "Sul"},
+	"SC": {"Santa Catarina", "Sul"},
+	"SE": {"Sergipe", "Nordeste"},
+	"SP": {"São Paulo", "Sudeste"},
+	"TO": {"Tocantins", "Norte"},
+}
+
+type addressQuery struct {
+	uf         string
+	cidade     string
+	logradouro string
+}
+
+func newAddressQuery(uf, cidade, logradouro string) (addressQuery, error) {
+	query := addressQuery{
+		uf:         strings.ToUpper(strings.TrimSpace(uf)),
+		cidade:     strings.TrimSpace(cidade),
+		logradouro: strings.TrimSpace(logradouro),
+	}
+
+	if _, ok := federativeUnits[query.uf]; !ok {
+		return addressQuery{}, &ValidationError{Field: "uf", Value: uf, Reason: "is not a valid federative unit"}
+	}
+
+	if utf8.RuneCountInString(query.cidade) < minSearchLength {
+		return addressQuery{}, &ValidationError{Field: "cidade", Value: cidade, Reason: "must have at least 3 characters"}
+	}
+
+	if utf8.RuneCountInString(query.logradouro) < minSearchLength {
+		return addressQuery{}, &ValidationError{Field: "logradouro", Value: logradouro, Reason: "must have at least 3 characters"}
+	}
+
+	return query, nil
+}
+
+// path returns the escaped "{uf}/{cidade}/{logradouro}" segments of the search URL.
+func (q addressQuery) path() string {
+	return url.PathEscape(q.uf) + "/" + url.PathEscape(q.cidade) + "/" + url.PathEscape(q.logradouro)
+}
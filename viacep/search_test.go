@@ -0,0 +1,72 @@
+package viacep
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViaCep_Search_newAddressQuery(t *testing.T) {
+	t.Run("valid parameters", func(t *testing.T) {
+		query, err := newAddressQuery(" rs ", " Porto Alegre ", "Domingos José")
+		assert.NoError(t, err)
+		assert.Equal(t, addressQuery{uf: "RS", cidade: "Porto Alegre", logradouro: "Domingos José"}, query)
+	})
+
+	t.Run("every federative unit", func(t *testing.T) {
+		assert.Len(t, federativeUnits, 27)
+
+		for uf := range federativeUnits {
+			_, err := newAddressQuery(uf, "Cidade", "Rua")
+			assert.NoError(t, err, uf)
+		}
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		testCases := []struct {
+			uf         string
+			cidade     string
+			logradouro string
+			expected   ValidationError
+		}{
+			{"XX", "Porto Alegre", "Domingos", ValidationError{Field: "uf", Value: "XX", Reason: "is not a valid federative unit"}},
+			{"", "Porto Alegre", "Domingos", ValidationError{Field: "uf", Value: "", Reason: "is not a valid federative unit"}},
+			{"R/S", "Porto Alegre", "Domingos", ValidationError{Field: "uf", Value: "R/S", Reason: "is not a valid federative unit"}},
+			{"RS", "PA", "Domingos", ValidationError{Field: "cidade", Value: "PA", Reason: "must have at least 3 characters"}},
+			{"RS", "  P  ", "Domingos", ValidationError{Field: "cidade", Value: "  P  ", Reason: "must have at least 3 characters"}},
+			{"RS", "Porto Alegre", "Do", ValidationError{Field: "logradouro", Value: "Do", Reason: "must have at least 3 characters"}},
+		}
+
+		for _, tc := range testCases {
+			_, err := newAddressQuery(tc.uf, tc.cidade, tc.logradouro)
+			assert.ErrorIs(t, err, ErrInvalidSearch)
+
+			var validationErr *ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tc.expected, *validationErr)
+		}
+	})
+
+	t.Run("accented characters count as one", func(t *testing.T) {
+		_, err := newAddressQuery("SP", "Itú", "Sé")
+		assert.EqualError(t, err, `invalid address search: logradouro "Sé" must have at least 3 characters`)
+
+		_, err = newAddressQuery("SP", "Itú", "Sés")
+		assert.NoError(t, err)
+	})
+}
+
+func TestViaCep_Search_path(t *testing.T) {
+	testCases := []struct {
+		query    addressQuery
+		expected string
+	}{
+		{addressQuery{uf: "RS", cidade: "Porto Alegre", logradouro: "Domingos José"}, "RS/Porto%20Alegre/Domingos%20Jos%C3%A9"},
+		{addressQuery{uf: "MG", cidade: "São João del-Rei", logradouro: "Rua 7/8"}, "MG/S%C3%A3o%20Jo%C3%A3o%20del-Rei/Rua%207%2F8"},
+		{addressQuery{uf: "SP", cidade: "São Paulo", logradouro: "../../x?y#z"}, "SP/S%C3%A3o%20Paulo/..%2F..%2Fx%3Fy%23z"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.query.path())
+	}
+}
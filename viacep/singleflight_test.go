@@ -0,0 +1,165 @@
+package viacep
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waiters returns the number of callers waiting on the in-flight call for key.
+func (g *flightGroup[T]) waiters(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if call, exists := g.calls[key]; exists {
+		return call.waiters
+	}
+
+	return 0
+}
+
+func TestViaCep_FlightGroup_do(t *testing.T) {
+	t.Run("concurrent callers share one call", func(t *testing.T) {
+		var group flightGroup[string]
+		var calls atomic.Int32
+		release := make(chan struct{})
+
+		const callers = 10
+		results := make(chan string, callers)
+
+		var wg sync.WaitGroup
+		for range callers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				val, err := group.do(context.Background(), "key", func(context.Context) (string, error) {
+					calls.Add(1)
+					<-release
+					return "value", nil
+				})
+				assert.NoError(t, err)
+				results <- val
+			}()
+		}
+
+		assert.Eventually(t, func() bool {
+			return group.waiters("key") == callers
+		}, time.Second, time.Millisecond)
+
+		close(release)
+		wg.Wait()
+		close(results)
+
+		assert.Equal(t, int32(1), calls.Load())
+		for val := range results {
+			assert.Equal(t, "value", val)
+		}
+	})
+
+	t.Run("error is shared", func(t *testing.T) {
+		var group flightGroup[string]
+		expected := errors.New("upstream error")
+
+		_, err := group.do(context.Background(), "key", func(context.Context) (string, error) {
+			return "", expected
+		})
+		assert.ErrorIs(t, err, expected)
+	})
+
+	t.Run("sequential calls run again", func(t *testing.T) {
+		var group flightGroup[int]
+		var calls atomic.Int32
+
+		for i := range 3 {
+			val, err := group.do(context.Background(), "key", func(context.Context) (int, error) {
+				return int(calls.Add(1)), nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, i+1, val)
+		}
+	})
+
+	t.Run("canceled caller does not cancel the others", func(t *testing.T) {
+		var group flightGroup[string]
+		release := make(chan struct{})
+		started := make(chan struct{})
+
+		var fnErr error
+		fn := func(ctx context.Context) (string, error) {
+			close(started)
+			<-release
+			fnErr = ctx.Err()
+			return "value", nil
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		canceled := make(chan error)
+		go func() {
+			_, err := group.do(ctx, "key", fn)
+			canceled <- err
+		}()
+
+		<-started
+		done := make(chan string)
+		go func() {
+			val, err := group.do(context.Background(), "key", fn)
+			assert.NoError(t, err)
+			done <- val
+		}()
+
+		assert.Eventually(t, func() bool {
+			return group.waiters("key") == 2
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-canceled, context.Canceled)
+
+		close(release)
+		assert.Equal(t, "value", <-done)
+		assert.NoError(t, fnErr)
+	})
+
+	t.Run("call is canceled when every caller gives up", func(t *testing.T) {
+		var group flightGroup[string]
+		fnCtx := make(chan context.Context, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := group.do(ctx, "key", func(ctx context.Context) (string, error) {
+			fnCtx <- ctx
+			<-ctx.Done()
+			return "", ctx.Err()
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		select {
+		case ctx := <-fnCtx:
+			<-ctx.Done()
+			assert.ErrorIs(t, ctx.Err(), context.Canceled)
+		case <-time.After(time.Second):
+			t.Fatal("shared call was not canceled")
+		}
+
+		assert.Equal(t, 0, group.waiters("key"))
+	})
+
+	t.Run("context values are kept", func(t *testing.T) {
+		type ctxKey struct{}
+
+		var group flightGroup[any]
+		ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+		val, err := group.do(ctx, "key", func(ctx context.Context) (any, error) {
+			return ctx.Value(ctxKey{}), nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "value", val)
+	})
+}
@@ -0,0 +1,120 @@
+package viacep
+
+import "sync/atomic"
+
+// CacheStats is a snapshot of the activity of a cache, suitable for export to a metrics system.
+// Counters are cumulative since the cache was created.
+type CacheStats struct {
+	// Hits is the number of reads answered from the cache.
+	Hits uint64
+	// Misses is the number of reads not answered from the cache, including DecodeFailures.
+	Misses uint64
+	// NegativeHits is the number of Hits that found an unknown CEP. Only ViaCep.Stats reports it.
+	NegativeHits uint64
+	// Sets is the number of values written successfully.
+	Sets uint64
+	// SetErrors is the number of values that could not be written.
+	SetErrors uint64
+	// Evictions is the number of entries removed because they expired or the cache was full.
+	Evictions uint64
+	// Entries is the number of entries held.
+	Entries int64
+	// Bytes is the size of the entries held.
+	Bytes int64
+	// DecodeFailures is the number of stored values that could not be decoded into the
+	// destination of a read.
+	DecodeFailures uint64
+}
+
+// StatsCache is an optional extension of Cache for caches that report their activity. All the
+// caches of this package implement it; ViaCep.Stats includes the figures of a StatsCache.
+type StatsCache interface {
+	Cache
+
+	// Stats returns a snapshot of the activity of the cache.
+	//
+	// Returns:
+	//   - The counters of the cache at the time of the call.
+	//
+	// Example:
+	//   stats := cache.Stats()
+	//   fmt.Printf("hit ratio: %.2f\n", stats.HitRatio())
+	Stats() CacheStats
+}
+
+// HitRatio returns Hits / (Hits + Misses), or 0 before the first read.
+func (s CacheStats) HitRatio() float64 {
+	reads := s.Hits + s.Misses
+	if reads == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(reads)
+}
+
+// Stats returns a snapshot of the cache activity of the client. Hits, Misses and NegativeHits
+// count the CEP and address lookups checked against the cache, and Sets and SetErrors the
+// results it stored. Evictions, Entries, Bytes and DecodeFailures are those of the cache when it
+// is a StatsCache, and zero otherwise.
+func (v *ViaCep) Stats() CacheStats {
+	stats := v.stats.snapshot()
+
+	if cache, ok := v.cache.(StatsCache); ok {
+		cacheStats := cache.Stats()
+		stats.Evictions = cacheStats.Evictions
+		stats.Entries = cacheStats.Entries
+		stats.Bytes = cacheStats.Bytes
+		stats.DecodeFailures = cacheStats.DecodeFailures
+	}
+
+	return stats
+}
+
+// cacheCounters holds the counters behind CacheStats. The zero value is ready to use.
+type cacheCounters struct {
+	hits           atomic.Uint64
+	misses         atomic.Uint64
+	negativeHits   atomic.Uint64
+	sets           atomic.Uint64
+	setErrors      atomic.Uint64
+	evictions      atomic.Uint64
+	decodeFailures atomic.Uint64
+}
+
+// read counts a read and returns found.
+func (c *cacheCounters) read(found bool) bool {
+	if found {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+
+	return found
+}
+
+// decodeFailure counts a read that found a value it could not decode.
+func (c *cacheCounters) decodeFailure() {
+	c.decodeFailures.Add(1)
+	c.misses.Add(1)
+}
+
+// write counts a write that ended with err.
+func (c *cacheCounters) write(err error) {
+	if err != nil {
+		c.setErrors.Add(1)
+	} else {
+		c.sets.Add(1)
+	}
+}
+
+func (c *cacheCounters) snapshot() CacheStats {
+	return CacheStats{
+		Hits:           c.hits.Load(),
+		Misses:         c.misses.Load(),
+		NegativeHits:   c.negativeHits.Load(),
+		Sets:           c.sets.Load(),
+		SetErrors:      c.setErrors.Load(),
+		Evictions:      c.evictions.Load(),
+		DecodeFailures: c.decodeFailures.Load(),
+	}
+}
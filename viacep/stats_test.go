@@ -0,0 +1,81 @@
+package viacep
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingCache is a Cache whose writes always fail.
+type failingCache struct {
+	Cache
+}
+
+func (failingCache) Set(context.Context, string, any, time.Duration) error {
+	return errors.New("error")
+}
+
+func TestViaCep_CacheStats_HitRatio(t *testing.T) {
+	assert.Zero(t, CacheStats{}.HitRatio())
+	assert.Equal(t, 0.75, CacheStats{Hits: 3, Misses: 1}.HitRatio())
+	assert.Equal(t, 1.0, CacheStats{Hits: 2}.HitRatio())
+}
+
+func TestViaCep_StatsCache_Implementations(t *testing.T) {
+	client, _ := redismock.NewClientMock()
+	disk := newTestDiskCache(t, t.TempDir())
+	memory := NewMemoryCache()
+	defer memory.Close()
+
+	for _, cache := range []Cache{memory, NewRedisCache(client), NewTieredCache(memory, WithL1(NewMemoryCache())), disk} {
+		_, ok := cache.(StatsCache)
+		assert.True(t, ok, "%T", cache)
+	}
+}
+
+func TestViaCep_Stats(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("lookups through a StatsCache", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		_, err := c.Cep(ctx, "01001000")
+		assert.NoError(t, err)
+		_, err = c.Cep(ctx, "01001000")
+		assert.NoError(t, err)
+		_, err = c.Cep(ctx, "99999999")
+		assert.ErrorIs(t, err, ErrCepNotFound)
+		_, err = c.Cep(ctx, "99999999")
+		assert.ErrorIs(t, err, ErrCepNotFound)
+
+		_, err = c.CepBatch(ctx, []string{"01001000", "01310100"}, BatchOptions{})
+		assert.NoError(t, err)
+
+		stats := c.Stats()
+		assert.Equal(t, CacheStats{
+			Hits:         3,
+			Misses:       3,
+			NegativeHits: 1,
+			Sets:         3,
+			Entries:      3,
+			Bytes:        stats.Bytes,
+		}, stats)
+		assert.Positive(t, stats.Bytes)
+	})
+
+	t.Run("set errors", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithCache(failingCache{noopCache{}}))
+
+		_, err := c.Cep(ctx, "01001000")
+		assert.NoError(t, err)
+
+		assert.Equal(t, CacheStats{Misses: 1, SetErrors: 1}, c.Stats())
+	})
+}
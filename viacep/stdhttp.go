@@ -0,0 +1,73 @@
+package viacep
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// StdHTTPClient implements HTTP with net/http and encoding/json only. It follows the same
+// RetryPolicy and returns the same *APIError values as HTTPClient.
+type StdHTTPClient struct {
+	client  *http.Client
+	retrier retrier
+}
+
+// NewStdHTTPClient creates a StdHTTPClient that retries failed requests up to maxRetry times
+// following DefaultRetryPolicy. Without WithStdClient or WithTransport, requests are sent
+// through http.DefaultTransport.
+func NewStdHTTPClient(maxRetry int, opts ...HTTPOption) *StdHTTPClient {
+	config := newHTTPConfig(maxRetry, opts)
+
+	return &StdHTTPClient{
+		client:  config.stdClient(),
+		retrier: config.retrier,
+	}
+}
+
+func (s *StdHTTPClient) Get(ctx context.Context, url string, dest any) error {
+	if reflect.ValueOf(dest).Kind() != reflect.Ptr {
+		return fmt.Errorf("expected a pointer for 'dest', but got %s", reflect.TypeOf(dest))
+	}
+
+	return s.retrier.run(ctx, url, func(ctx context.Context) attempt {
+		return s.send(ctx, url, dest)
+	})
+}
+
+func (s *StdHTTPClient) send(ctx context.Context, url string, dest any) attempt {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return attempt{err: err}
+	}
+
+	for key, value := range headersDefault {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return attempt{err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return attempt{err: err}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return attempt{statusCode: resp.StatusCode, header: resp.Header, body: body}
+	}
+
+	if raw, ok := dest.(*[]byte); ok {
+		*raw = body
+	} else if err := json.Unmarshal(body, dest); err != nil {
+		return attempt{statusCode: resp.StatusCode, header: resp.Header, body: body, err: err}
+	}
+
+	return attempt{statusCode: resp.StatusCode, header: resp.Header}
+}
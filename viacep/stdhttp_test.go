@@ -0,0 +1,84 @@
+package viacep
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestViaCep_StdHTTPClient_Get(t *testing.T) {
+	testHTTPConformance(t, func(maxRetry int, opts ...HTTPOption) HTTP {
+		return NewStdHTTPClient(maxRetry, opts...)
+	})
+
+	t.Run("custom transport", func(t *testing.T) {
+		var requests []*http.Request
+		transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"cep": "01001-000"}`)),
+			}, nil
+		})
+
+		var address Address
+		err := NewStdHTTPClient(0, WithTransport(transport)).Get(context.Background(), "http://viacep.local/ws/01001000/json/", &address)
+		assert.NoError(t, err)
+		assert.Equal(t, "01001-000", address.Cep)
+		assert.Len(t, requests, 1)
+		assert.Equal(t, "application/json", requests[0].Header.Get("Accept"))
+	})
+
+	t.Run("custom client is copied", func(t *testing.T) {
+		client := &http.Client{Timeout: time.Second}
+		transport := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return nil, io.ErrUnexpectedEOF
+		})
+
+		std := NewStdHTTPClient(0, WithStdClient(client), WithTransport(transport))
+		assert.Equal(t, time.Second, std.client.Timeout)
+		assert.Nil(t, client.Transport)
+
+		err := std.Get(context.Background(), "http://viacep.local", &Address{})
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"cep":`))
+		}))
+		defer srv.Close()
+
+		err := NewStdHTTPClient(0).Get(context.Background(), srv.URL, &Address{})
+
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 1, apiErr.Attempts)
+	})
+
+	t.Run("with provider", func(t *testing.T) {
+		srv := newFixtureServer(t, map[string]fixture{
+			"/api/cep/v2/01001000": {http.StatusOK, "brasilapi_01001000.json"},
+		})
+
+		address, err := NewBrasilAPI(WithProviderHTTP(NewStdHTTPClient(0)), WithProviderBaseURL(srv.URL)).
+			Cep(context.Background(), "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, "Praça da Sé", address.Logradouro)
+	})
+}
@@ -0,0 +1,196 @@
+package viacep
+
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
+// StreamOrder selects the order in which a stream emits its results.
+type StreamOrder int
+
+const (
+	// OrderInput emits results in the order of the input CEPs. A slow lookup holds back the
+	// results that follow it, so at most 2*Concurrency results are buffered.
+	OrderInput StreamOrder = iota
+	// OrderCompleted emits each result as soon as its lookup completes.
+	OrderCompleted
+)
+
+// StreamOptions configures CepStream and CepStreamChan.
+type StreamOptions struct {
+	// Concurrency is the maximum number of CEPs looked up at once. Defaults to 8.
+	Concurrency int
+	// RateLimiter paces the API requests of this stream, on top of any limiter set with
+	// WithRateLimiter. Cache hits do not consume permits.
+	RateLimiter RateLimiter
+	// Order is the order of the results. Defaults to OrderInput.
+	Order StreamOrder
+}
+
+// CepStream looks up the CEPs produced by ceps and yields one BatchResult per input, paired with
+// the error of that lookup, so per-item failures such as ErrInvalidCEP do not end the stream.
+//
+// Unlike CepBatch, inputs are consumed lazily and never held in memory as a whole: at most
+// opts.Concurrency lookups run at once and ceps is not advanced while they are all busy.
+// Repeated CEPs are not deduplicated, but concurrent lookups of the same CEP still share a
+// single request and later ones are served from the cache.
+//
+// Breaking out of the range loop stops consuming ceps and cancels the running lookups. ceps is
+// no longer running once the loop has exited, so its resources can be released right after.
+// If ctx is done first, the stream ends with a zero BatchResult paired with the context error.
+//
+// Example:
+//
+//	for result, err := range client.CepStream(ctx, ceps, viacep.StreamOptions{}) {
+//		if err != nil {
+//			log.Printf("%s: %v", result.Input, err)
+//			continue
+//		}
+//		fmt.Println(result.Address.Logradouro)
+//	}
+func (v *ViaCep) CepStream(ctx context.Context, ceps iter.Seq[string], opts StreamOptions) iter.Seq2[BatchResult, error] {
+	return func(yield func(BatchResult, error) bool) {
+		streamCtx, cancel := context.WithCancel(ctx)
+		results, produced := v.stream(streamCtx, ceps, opts)
+		defer func() {
+			cancel()
+			<-produced
+		}()
+
+		for result := range results {
+			if !yield(result, result.Err) {
+				return
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			yield(BatchResult{}, err)
+		}
+	}
+}
+
+// CepStreamChan is the channel based variant of CepStream. It looks up the CEPs received from
+// ceps until it is closed and sends one BatchResult per input on the returned channel, which is
+// closed once every lookup is done.
+//
+// To stop early, cancel ctx: the stream stops receiving from ceps, results not yet sent are
+// dropped and the returned channel is closed shortly after.
+func (v *ViaCep) CepStreamChan(ctx context.Context, ceps <-chan string, opts StreamOptions) <-chan BatchResult {
+	results, _ := v.stream(ctx, receiveAll(ctx, ceps), opts)
+	return results
+}
+
+// stream runs the lookups of ceps on a pool of workers and sends their results on the returned
+// channel, in the order selected by opts. The second channel is closed once ceps is no longer
+// iterated, so that callers can make sure the iterator is not running after they return.
+func (v *ViaCep) stream(ctx context.Context, ceps iter.Seq[string], opts StreamOptions) (<-chan BatchResult, <-chan struct{}) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	produced := make(chan struct{})
+	inputs := func(yield func(string) bool) {
+		defer close(produced)
+		ceps(yield)
+	}
+
+	out := make(chan BatchResult)
+	if opts.Order == OrderCompleted {
+		go v.streamCompleted(ctx, inputs, opts.RateLimiter, concurrency, out)
+	} else {
+		go v.streamOrdered(ctx, inputs, opts.RateLimiter, concurrency, out)
+	}
+
+	return out, produced
+}
+
+// streamCompleted sends each result on out as soon as its lookup completes.
+func (v *ViaCep) streamCompleted(ctx context.Context, ceps iter.Seq[string], limiter RateLimiter, concurrency int, out chan<- BatchResult) {
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	slots := make(chan struct{}, concurrency)
+	for input := range ceps {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := v.lookupInput(ctx, input, limiter)
+			<-slots
+
+			select {
+			case out <- result:
+			case <-ctx.Done():
+			}
+		}()
+	}
+}
+
+// streamOrdered sends the results on out in input order. Each lookup writes its result to its
+// own pending channel, and pending channels are queued in input order for the sender.
+func (v *ViaCep) streamOrdered(ctx context.Context, ceps iter.Seq[string], limiter RateLimiter, concurrency int, out chan<- BatchResult) {
+	queue := make(chan chan BatchResult, concurrency)
+
+	go func() {
+		defer close(out)
+
+		for pending := range queue {
+			result := <-pending
+
+			select {
+			case out <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	defer close(queue)
+
+	slots := make(chan struct{}, concurrency)
+	for input := range ceps {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		pending := make(chan BatchResult, 1)
+		select {
+		case queue <- pending:
+		case <-ctx.Done():
+			return
+		}
+
+		go func() {
+			pending <- v.lookupInput(ctx, input, limiter)
+			<-slots
+		}()
+	}
+}
+
+// receiveAll adapts ceps to an iterator that ends when ceps is closed or ctx is done.
+func receiveAll(ctx context.Context, ceps <-chan string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for {
+			select {
+			case cep, ok := <-ceps:
+				if !ok || !yield(cep) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
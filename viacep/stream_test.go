@@ -0,0 +1,277 @@
+package viacep
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// trackedSeq yields inputs, counting how many were pulled and closing stopped once the
+// iteration is over.
+type trackedSeq struct {
+	inputs  []string
+	pulled  atomic.Int32
+	stopped chan struct{}
+}
+
+func newTrackedSeq(inputs []string) *trackedSeq {
+	return &trackedSeq{inputs: inputs, stopped: make(chan struct{})}
+}
+
+func (s *trackedSeq) seq() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		defer close(s.stopped)
+
+		for _, input := range s.inputs {
+			s.pulled.Add(1)
+			if !yield(input) {
+				return
+			}
+		}
+	}
+}
+
+// newGatedServer echoes every CEP, holding the requests for held until release is closed.
+func newGatedServer(t *testing.T, held string, release <-chan struct{}) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cep := strings.Split(r.URL.Path, "/")[2]
+		if cep == held {
+			<-release
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"cep": "` + cep + `"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func sequentialCeps(n int) []string {
+	ceps := make([]string, n)
+	for i := range ceps {
+		ceps[i] = fmt.Sprintf("010010%02d", i)
+	}
+
+	return ceps
+}
+
+func TestViaCep_CepStream(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("input order", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+
+		inputs := []string{"01001-000", "invalid", "99999999", "01310100"}
+		var results []BatchResult
+		for result, err := range c.CepStream(ctx, slices.Values(inputs), StreamOptions{Concurrency: 2}) {
+			assert.Equal(t, result.Err, err)
+			results = append(results, result)
+		}
+
+		assert.Len(t, results, len(inputs))
+		for i, result := range results {
+			assert.Equal(t, inputs[i], result.Input)
+		}
+
+		assert.Equal(t, &Address{Cep: "01001000"}, results[0].Address)
+		assert.ErrorIs(t, results[1].Err, ErrInvalidCEP)
+		assert.ErrorIs(t, results[2].Err, ErrCepNotFound)
+		assert.Equal(t, &Address{Cep: "01310100"}, results[3].Address)
+	})
+
+	t.Run("slow lookup holds back input order", func(t *testing.T) {
+		release := make(chan struct{})
+		srv := newGatedServer(t, "01001000", release)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+
+		inputs := []string{"01001000", "01001001", "01001002"}
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+		}()
+
+		var order []string
+		for result, err := range c.CepStream(ctx, slices.Values(inputs), StreamOptions{}) {
+			assert.NoError(t, err)
+			order = append(order, result.Input)
+		}
+
+		assert.Equal(t, inputs, order)
+	})
+
+	t.Run("as completed", func(t *testing.T) {
+		release := make(chan struct{})
+		srv := newGatedServer(t, "01001000", release)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+
+		var order []string
+		for result, err := range c.CepStream(ctx, slices.Values([]string{"01001000", "01001001", "01001002"}), StreamOptions{Order: OrderCompleted}) {
+			assert.NoError(t, err)
+			order = append(order, result.Input)
+			if len(order) == 2 {
+				close(release)
+			}
+		}
+
+		assert.ElementsMatch(t, []string{"01001001", "01001002"}, order[:2])
+		assert.Equal(t, "01001000", order[2])
+	})
+
+	t.Run("bounded concurrency", func(t *testing.T) {
+		for _, order := range []StreamOrder{OrderInput, OrderCompleted} {
+			srv := newBatchServer(t)
+			c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+
+			count := 0
+			for _, err := range c.CepStream(ctx, slices.Values(sequentialCeps(30)), StreamOptions{Concurrency: 3, Order: order}) {
+				assert.NoError(t, err)
+				count++
+			}
+
+			assert.Equal(t, 30, count)
+			assert.Len(t, srv.requests(), 30)
+			assert.LessOrEqual(t, srv.maxInFlight.Load(), int32(3))
+		}
+	})
+
+	t.Run("early break", func(t *testing.T) {
+		for _, order := range []StreamOrder{OrderInput, OrderCompleted} {
+			srv := newBatchServer(t)
+			c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+			inputs := newTrackedSeq(sequentialCeps(50))
+
+			count := 0
+			for _, err := range c.CepStream(ctx, inputs.seq(), StreamOptions{Concurrency: 2, Order: order}) {
+				assert.NoError(t, err)
+				count++
+				if count == 3 {
+					break
+				}
+			}
+
+			select {
+			case <-inputs.stopped:
+			default:
+				t.Fatal("input iteration still running after break")
+			}
+
+			pulled := inputs.pulled.Load()
+			time.Sleep(20 * time.Millisecond)
+			assert.Equal(t, pulled, inputs.pulled.Load())
+
+			assert.Equal(t, 3, count)
+			assert.Less(t, pulled, int32(50))
+			assert.Less(t, len(srv.requests()), 50)
+		}
+	})
+
+	t.Run("canceled context ends the stream", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+		inputs := newTrackedSeq(sequentialCeps(50))
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var last error
+		for result, err := range c.CepStream(ctx, inputs.seq(), StreamOptions{Concurrency: 1}) {
+			if result.Input == "01001001" {
+				cancel()
+			}
+			last = err
+		}
+
+		assert.ErrorIs(t, last, context.Canceled)
+		<-inputs.stopped
+		assert.Less(t, inputs.pulled.Load(), int32(50))
+	})
+
+	t.Run("cache hits", func(t *testing.T) {
+		srv := newBatchServer(t)
+		limiter := &countingLimiter{}
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		_, err := c.Cep(ctx, "01001000")
+		assert.NoError(t, err)
+
+		for _, err := range c.CepStream(ctx, slices.Values([]string{"01001000", "01310100"}), StreamOptions{RateLimiter: limiter}) {
+			assert.NoError(t, err)
+		}
+
+		assert.Len(t, srv.requests(), 2)
+		assert.Equal(t, int32(1), limiter.permits.Load())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		for range New(WithNoCache()).CepStream(ctx, slices.Values([]string(nil)), StreamOptions{}) {
+			t.Fatal("unexpected result")
+		}
+	})
+}
+
+func TestViaCep_CepStreamChan(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("results", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+
+		ceps := make(chan string)
+		go func() {
+			defer close(ceps)
+			for _, cep := range []string{"01001000", "bad", "99999999"} {
+				ceps <- cep
+			}
+		}()
+
+		var results []BatchResult
+		for result := range c.CepStreamChan(ctx, ceps, StreamOptions{}) {
+			results = append(results, result)
+		}
+
+		assert.Len(t, results, 3)
+		assert.Equal(t, &Address{Cep: "01001000"}, results[0].Address)
+		assert.ErrorIs(t, results[1].Err, ErrInvalidCEP)
+		assert.ErrorIs(t, results[2].Err, ErrCepNotFound)
+	})
+
+	t.Run("cancel closes the results", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithNoCache())
+
+		ctx, cancel := context.WithCancel(ctx)
+		ceps := make(chan string)
+		results := c.CepStreamChan(ctx, ceps, StreamOptions{Order: OrderCompleted})
+
+		ceps <- "01001000"
+		assert.NoError(t, (<-results).Err)
+		cancel()
+
+		closed := make(chan struct{})
+		go func() {
+			for range results {
+			}
+			close(closed)
+		}()
+
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("results were not closed after cancel")
+		}
+	})
+}
@@ -0,0 +1,259 @@
+package viacep
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	defaultL1TTL        = time.Minute
+	defaultL1MaxEntries = 10_000
+)
+
+// TieredCache is a Cache that keeps a small in-process L1 MemoryCache in front of a shared L2
+// cache such as a RedisCache. Reads are served from L1 when possible and L2 hits are copied to
+// L1 for a short TTL; writes and deletes go to both tiers.
+//
+// Other instances sharing the L2 cache may keep stale copies in their own L1 for up to the L1
+// TTL. With WithInvalidation, every write and delete is announced on a Redis channel so that
+// the other instances evict their copies straight away.
+type TieredCache struct {
+	l1           *MemoryCache
+	l2           Cache
+	l1TTL        time.Duration
+	ownedL1      bool
+	client       *redis.Client
+	channel      string
+	id           string
+	subscription *redis.PubSub
+	closeOnce    sync.Once
+	counters     cacheCounters
+}
+
+// TieredCacheOption configures a TieredCache created with NewTieredCache.
+type TieredCacheOption func(*TieredCache)
+
+// WithL1 sets the MemoryCache used as L1. Defaults to a MemoryCache holding up to 10000 entries,
+// which is closed together with the TieredCache; a cache supplied here is not.
+func WithL1(l1 *MemoryCache) TieredCacheOption {
+	return func(c *TieredCache) {
+		c.l1 = l1
+	}
+}
+
+// WithL1TTL sets how long entries are kept in L1. Entries written with a shorter TTL keep it.
+// Defaults to one minute.
+//
+// Entries copied to L1 by Get and GetMulti are kept for the full L1 TTL, since the time left
+// before they expire from L2 is unknown. Such an entry may thus be served from L1 for up to the
+// L1 TTL after it expired from L2; keep the L1 TTL well below the TTLs written to L2 when that
+// window matters.
+func WithL1TTL(ttl time.Duration) TieredCacheOption {
+	return func(c *TieredCache) {
+		c.l1TTL = ttl
+	}
+}
+
+// WithInvalidation publishes the keys written or deleted through the cache on channel, and
+// evicts from L1 the keys published there by other instances.
+func WithInvalidation(client *redis.Client, channel string) TieredCacheOption {
+	return func(c *TieredCache) {
+		c.client = client
+		c.channel = channel
+	}
+}
+
+// NewTieredCache creates a TieredCache in front of l2. Call Close to stop the background work
+// of the L1 cache and the invalidation subscription.
+func NewTieredCache(l2 Cache, opts ...TieredCacheOption) *TieredCache {
+	c := &TieredCache{
+		l2:    l2,
+		l1TTL: defaultL1TTL,
+		id:    strconv.FormatUint(rand.Uint64(), 36),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.l1 == nil {
+		c.l1 = NewMemoryCache(WithMaxEntries(defaultL1MaxEntries))
+		c.ownedL1 = true
+	}
+
+	if c.client != nil {
+		c.subscription = c.client.Subscribe(context.Background(), c.channel)
+		go c.listen(c.subscription.Channel())
+	}
+
+	return c
+}
+
+func (c *TieredCache) Get(ctx context.Context, key string, dest any) bool {
+	if c.l1.Get(ctx, key, dest) {
+		return c.counters.read(true)
+	}
+
+	if !c.l2.Get(ctx, key, dest) {
+		return c.counters.read(false)
+	}
+
+	_ = c.l1.Set(ctx, key, dest, c.l1TTL)
+	return c.counters.read(true)
+}
+
+func (c *TieredCache) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	err := c.l2.Set(ctx, key, value, ttl)
+	if err != nil {
+		_ = c.l1.Delete(ctx, key)
+	} else {
+		err = c.l1.Set(ctx, key, value, c.l1EntryTTL(ttl))
+	}
+
+	c.counters.write(err)
+	if err != nil {
+		return err
+	}
+
+	return c.publish(ctx, key)
+}
+
+func (c *TieredCache) Delete(ctx context.Context, key string) error {
+	_ = c.l1.Delete(ctx, key)
+	if err := c.l2.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	return c.publish(ctx, key)
+}
+
+// GetMulti reads the keys missing from L1 from L2 in a single call when L2 is a BatchCache,
+// and copies the L2 hits to L1.
+func (c *TieredCache) GetMulti(ctx context.Context, keys []string, destFactory func() any) map[string]any {
+	found := c.l1.GetMulti(ctx, keys, destFactory)
+	if len(found) == len(keys) {
+		c.counters.hits.Add(uint64(len(found)))
+		return found
+	}
+
+	missing := make([]string, 0, len(keys)-len(found))
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	backfill := getMulti(ctx, c.l2, missing, destFactory)
+	_ = c.l1.SetMulti(ctx, backfill, c.l1TTL)
+	maps.Copy(found, backfill)
+
+	c.counters.hits.Add(uint64(len(found)))
+	c.counters.misses.Add(uint64(len(keys) - len(found)))
+	return found
+}
+
+// SetMulti writes items to L2 in a single call when L2 is a BatchCache, then to L1.
+func (c *TieredCache) SetMulti(ctx context.Context, items map[string]any, ttl time.Duration) error {
+	keys := slices.Sorted(maps.Keys(items))
+
+	err := setMulti(ctx, c.l2, items, ttl)
+	if err != nil {
+		for _, key := range keys {
+			_ = c.l1.Delete(ctx, key)
+		}
+	} else {
+		err = c.l1.SetMulti(ctx, items, c.l1EntryTTL(ttl))
+	}
+
+	if err != nil {
+		c.counters.setErrors.Add(uint64(len(items)))
+		return err
+	}
+
+	c.counters.sets.Add(uint64(len(items)))
+
+	return c.publish(ctx, keys...)
+}
+
+// Stats returns a snapshot of the activity of the cache. Hits and Misses count the reads answered
+// by either tier and DecodeFailures those of both tiers, while Evictions, Entries and Bytes
+// describe L1. The figures of the shared L2 are available from its own Stats.
+func (c *TieredCache) Stats() CacheStats {
+	stats := c.counters.snapshot()
+
+	l1 := c.l1.Stats()
+	stats.Evictions, stats.Entries, stats.Bytes = l1.Evictions, l1.Entries, l1.Bytes
+	stats.DecodeFailures = l1.DecodeFailures
+	if l2, ok := c.l2.(StatsCache); ok {
+		stats.DecodeFailures += l2.Stats().DecodeFailures
+	}
+
+	return stats
+}
+
+// Close stops the invalidation subscription and the default L1 cache. L1 and L2 caches supplied
+// by the caller are left open.
+func (c *TieredCache) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		if c.subscription != nil {
+			err = c.subscription.Close()
+		}
+
+		if c.ownedL1 {
+			_ = c.l1.Close()
+		}
+	})
+
+	return err
+}
+
+func (c *TieredCache) l1EntryTTL(ttl time.Duration) time.Duration {
+	if ttl > 0 {
+		return min(ttl, c.l1TTL)
+	}
+
+	return c.l1TTL
+}
+
+// publish announces keys on the invalidation channel. Messages hold the id of the publishing
+// instance followed by one key per line, so that instances can skip their own messages.
+func (c *TieredCache) publish(ctx context.Context, keys ...string) error {
+	if c.client == nil || len(keys) == 0 {
+		return nil
+	}
+
+	message := c.id + "\n" + strings.Join(keys, "\n")
+	if err := c.client.Publish(ctx, c.channel, message).Err(); err != nil {
+		return fmt.Errorf("failed to publish cache invalidation: %w", err)
+	}
+
+	return nil
+}
+
+func (c *TieredCache) listen(messages <-chan *redis.Message) {
+	for message := range messages {
+		c.invalidate(message.Payload)
+	}
+}
+
+// invalidate evicts from L1 the keys of a message published by another instance.
+func (c *TieredCache) invalidate(payload string) {
+	origin, keys, ok := strings.Cut(payload, "\n")
+	if !ok || origin == c.id {
+		return
+	}
+
+	for _, key := range strings.Split(keys, "\n") {
+		_ = c.l1.Delete(context.Background(), key)
+	}
+}
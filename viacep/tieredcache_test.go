@@ -0,0 +1,374 @@
+package viacep
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/go-redis/redismock/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestTieredCache returns a TieredCache over a mocked RedisCache with an L1 driven by clock.
+func newTestTieredCache(t *testing.T, clock Clock, opts ...TieredCacheOption) (*TieredCache, redismock.ClientMock) {
+	t.Helper()
+
+	client, mock := redismock.NewClientMock()
+	l1 := NewMemoryCache(WithMemoryCacheClock(clock), WithJanitorInterval(0))
+	cache := NewTieredCache(NewRedisCache(client), append([]TieredCacheOption{WithL1(l1)}, opts...)...)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	return cache, mock
+}
+
+// newPubSubServer starts a fake Redis server that confirms the first SUBSCRIBE it receives and
+// then pushes the payloads sent on the returned channel as messages. The last returned channel
+// is closed once the client disconnects.
+func newPubSubServer(t *testing.T) (addr string, publish chan<- string, disconnected <-chan struct{}) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	payloads := make(chan string)
+	done := make(chan struct{})
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// SUBSCRIBE arrives as an array of bulk strings: one header line, then two lines per element.
+		reader := bufio.NewReader(conn)
+		header, _ := reader.ReadString('\n')
+		elements, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "*")))
+		command := make([]string, 0, elements)
+		for range elements {
+			_, _ = reader.ReadString('\n')
+			line, _ := reader.ReadString('\n')
+			command = append(command, strings.TrimSpace(line))
+		}
+
+		for i, channel := range command[1:] {
+			_, _ = fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:%d\r\n", len(channel), channel, i+1)
+		}
+
+		go func() {
+			defer close(done)
+			_, _ = io.Copy(io.Discard, reader)
+		}()
+
+		for {
+			select {
+			case payload := <-payloads:
+				_, _ = fmt.Fprintf(conn, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
+					len(command[1]), command[1], len(payload), payload)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return listener.Addr().String(), payloads, done
+}
+
+func TestViaCep_TieredCache_Get(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("L2 hit backfills L1", func(t *testing.T) {
+		cache, mock := newTestTieredCache(t, newFakeClock())
+		mock.ExpectGet("user:1").SetVal(string(encodeGob(t, "alice")))
+
+		var dest string
+		assert.True(t, cache.Get(ctx, "user:1", &dest))
+		assert.Equal(t, "alice", dest)
+
+		dest = ""
+		assert.True(t, cache.Get(ctx, "user:1", &dest))
+		assert.Equal(t, "alice", dest)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("L1 entries expire after the L1 TTL", func(t *testing.T) {
+		clock := newFakeClock()
+		cache, mock := newTestTieredCache(t, clock, WithL1TTL(time.Second))
+		mock.ExpectGet("user:1").SetVal(string(encodeGob(t, "alice")))
+		mock.ExpectGet("user:1").SetVal(string(encodeGob(t, "alicia")))
+
+		var dest string
+		assert.True(t, cache.Get(ctx, "user:1", &dest))
+		clock.Advance(time.Second)
+
+		assert.True(t, cache.Get(ctx, "user:1", &dest))
+		assert.Equal(t, "alicia", dest)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("miss", func(t *testing.T) {
+		cache, mock := newTestTieredCache(t, newFakeClock())
+		mock.ExpectGet("user:1").RedisNil()
+		mock.ExpectGet("user:1").SetErr(errors.New("error"))
+
+		var dest string
+		assert.False(t, cache.Get(ctx, "user:1", &dest))
+		assert.False(t, cache.Get(ctx, "user:1", &dest))
+		assert.Empty(t, dest)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestViaCep_TieredCache_Set(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("writes both tiers", func(t *testing.T) {
+		clock := newFakeClock()
+		cache, mock := newTestTieredCache(t, clock)
+		mock.ExpectSet("user:1", encodeGob(t, "alice"), time.Hour).SetVal("OK")
+
+		assert.NoError(t, cache.Set(ctx, "user:1", "alice", time.Hour))
+
+		var dest string
+		assert.True(t, cache.l1.Get(ctx, "user:1", &dest))
+		assert.Equal(t, "alice", dest)
+
+		clock.Advance(defaultL1TTL)
+		assert.False(t, cache.l1.Get(ctx, "user:1", &dest))
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("shorter TTL is kept in L1", func(t *testing.T) {
+		clock := newFakeClock()
+		cache, mock := newTestTieredCache(t, clock)
+		mock.ExpectSet("user:1", encodeGob(t, "alice"), time.Second).SetVal("OK")
+
+		assert.NoError(t, cache.Set(ctx, "user:1", "alice", time.Second))
+		clock.Advance(time.Second)
+
+		var dest string
+		assert.False(t, cache.l1.Get(ctx, "user:1", &dest))
+	})
+
+	t.Run("L2 error evicts L1", func(t *testing.T) {
+		cache, mock := newTestTieredCache(t, newFakeClock())
+		assert.NoError(t, cache.l1.Set(ctx, "user:1", "alice", 0))
+		mock.ExpectSet("user:1", encodeGob(t, "bob"), 0).SetErr(errors.New("error"))
+
+		err := cache.Set(ctx, "user:1", "bob", 0)
+		assert.EqualError(t, err, "failed to set value in cache: error")
+		assert.Equal(t, 0, cache.l1.Len())
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestViaCep_TieredCache_Delete(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("deletes both tiers", func(t *testing.T) {
+		cache, mock := newTestTieredCache(t, newFakeClock())
+		assert.NoError(t, cache.l1.Set(ctx, "user:1", "alice", 0))
+		mock.ExpectDel("user:1").SetVal(1)
+
+		assert.NoError(t, cache.Delete(ctx, "user:1"))
+		assert.Equal(t, 0, cache.l1.Len())
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("L2 error", func(t *testing.T) {
+		cache, mock := newTestTieredCache(t, newFakeClock())
+		assert.NoError(t, cache.l1.Set(ctx, "user:1", "alice", 0))
+		mock.ExpectDel("user:1").SetErr(errors.New("error"))
+
+		assert.EqualError(t, cache.Delete(ctx, "user:1"), "failed to delete key from cache: error")
+		assert.Equal(t, 0, cache.l1.Len())
+	})
+}
+
+func TestViaCep_TieredCache_GetMulti(t *testing.T) {
+	ctx := context.Background()
+	cache, mock := newTestTieredCache(t, newFakeClock())
+	assert.NoError(t, cache.l1.Set(ctx, "user:1", "alice", 0))
+
+	mock.ExpectMGet("user:2", "user:3").SetVal([]any{string(encodeGob(t, "bob")), nil})
+
+	found := cache.GetMulti(ctx, []string{"user:1", "user:2", "user:3"}, func() any { return new(string) })
+	assert.Len(t, found, 2)
+	assert.Equal(t, "alice", *found["user:1"].(*string))
+	assert.Equal(t, "bob", *found["user:2"].(*string))
+
+	found = cache.GetMulti(ctx, []string{"user:1", "user:2"}, func() any { return new(string) })
+	assert.Len(t, found, 2)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestViaCep_TieredCache_SetMulti(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("writes both tiers", func(t *testing.T) {
+		cache, mock := newTestTieredCache(t, newFakeClock())
+		mock.ExpectSet("user:1", encodeGob(t, "alice"), time.Hour).SetVal("OK")
+		mock.ExpectSet("user:2", encodeGob(t, "bob"), time.Hour).SetVal("OK")
+
+		assert.NoError(t, cache.SetMulti(ctx, map[string]any{"user:1": "alice", "user:2": "bob"}, time.Hour))
+		assert.Equal(t, 2, cache.l1.Len())
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("L2 error evicts L1", func(t *testing.T) {
+		cache, mock := newTestTieredCache(t, newFakeClock())
+		assert.NoError(t, cache.l1.Set(ctx, "user:1", "alice", 0))
+		mock.ExpectSet("user:1", encodeGob(t, "alicia"), 0).SetErr(errors.New("error"))
+
+		err := cache.SetMulti(ctx, map[string]any{"user:1": "alicia"}, 0)
+		assert.EqualError(t, err, "failed to set values in cache: error")
+		assert.Equal(t, 0, cache.l1.Len())
+	})
+}
+
+func TestViaCep_TieredCache_Invalidation(t *testing.T) {
+	ctx := context.Background()
+
+	// withPublisher enables publishing on a mocked client without subscribing, which the mock
+	// does not support.
+	withPublisher := func(cache *TieredCache) redismock.ClientMock {
+		client, mock := redismock.NewClientMock()
+		cache.client, cache.channel, cache.id = client, "viacep:invalidate", "pod-a"
+		return mock
+	}
+
+	t.Run("writes and deletes are published", func(t *testing.T) {
+		cache, mock := newTestTieredCache(t, newFakeClock())
+		publisher := withPublisher(cache)
+
+		mock.ExpectSet("user:1", encodeGob(t, "alice"), 0).SetVal("OK")
+		publisher.ExpectPublish("viacep:invalidate", "pod-a\nuser:1").SetVal(1)
+		assert.NoError(t, cache.Set(ctx, "user:1", "alice", 0))
+
+		mock.ExpectSet("user:1", encodeGob(t, "alice"), 0).SetVal("OK")
+		mock.ExpectSet("user:2", encodeGob(t, "bob"), 0).SetVal("OK")
+		publisher.ExpectPublish("viacep:invalidate", "pod-a\nuser:1\nuser:2").SetVal(1)
+		assert.NoError(t, cache.SetMulti(ctx, map[string]any{"user:2": "bob", "user:1": "alice"}, 0))
+
+		mock.ExpectDel("user:1").SetVal(1)
+		publisher.ExpectPublish("viacep:invalidate", "pod-a\nuser:1").SetVal(1)
+		assert.NoError(t, cache.Delete(ctx, "user:1"))
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.NoError(t, publisher.ExpectationsWereMet())
+	})
+
+	t.Run("publish error", func(t *testing.T) {
+		cache, mock := newTestTieredCache(t, newFakeClock())
+		publisher := withPublisher(cache)
+
+		mock.ExpectSet("user:1", encodeGob(t, "alice"), 0).SetVal("OK")
+		publisher.ExpectPublish("viacep:invalidate", "pod-a\nuser:1").SetErr(errors.New("error"))
+
+		err := cache.Set(ctx, "user:1", "alice", 0)
+		assert.EqualError(t, err, "failed to publish cache invalidation: error")
+	})
+
+	t.Run("subscribes on creation until closed", func(t *testing.T) {
+		addr, publish, disconnected := newPubSubServer(t)
+		client := redis.NewClient(&redis.Options{Addr: addr})
+		defer client.Close()
+
+		l1 := NewMemoryCache()
+		defer l1.Close()
+		cache := NewTieredCache(noopCache{}, WithL1(l1), WithInvalidation(client, "viacep:invalidate"))
+		assert.NotNil(t, cache.subscription)
+		assert.NoError(t, l1.Set(ctx, "user:1", "alice", 0))
+
+		publish <- "pod-b\nuser:1"
+		assert.Eventually(t, func() bool {
+			var dest string
+			return !l1.Get(ctx, "user:1", &dest)
+		}, time.Second, time.Millisecond)
+
+		assert.NoError(t, cache.Close())
+		assert.ErrorIs(t, cache.subscription.Close(), redis.ErrClosed)
+		select {
+		case <-disconnected:
+		case <-time.After(time.Second):
+			t.Fatal("subscription connection still open after Close")
+		}
+	})
+
+	t.Run("messages from other instances evict L1", func(t *testing.T) {
+		cache, _ := newTestTieredCache(t, newFakeClock())
+		cache.id = "pod-a"
+		assert.NoError(t, cache.l1.SetMulti(ctx, map[string]any{"user:1": "alice", "user:2": "bob", "user:3": "carol"}, 0))
+
+		messages := make(chan *redis.Message, 3)
+		messages <- &redis.Message{Payload: "pod-a\nuser:3"}
+		messages <- &redis.Message{Payload: "pod-b\nuser:1\nuser:2"}
+		messages <- &redis.Message{Payload: "malformed"}
+		close(messages)
+		cache.listen(messages)
+
+		var dest string
+		assert.False(t, cache.l1.Get(ctx, "user:1", &dest))
+		assert.False(t, cache.l1.Get(ctx, "user:2", &dest))
+		assert.True(t, cache.l1.Get(ctx, "user:3", &dest))
+	})
+}
+
+func TestViaCep_TieredCache_Close(t *testing.T) {
+	cache := NewTieredCache(NewMemoryCache())
+	assert.True(t, cache.ownedL1)
+	assert.NoError(t, cache.Close())
+	assert.NoError(t, cache.Close())
+
+	l1 := NewMemoryCache()
+	defer l1.Close()
+	cache = NewTieredCache(noopCache{}, WithL1(l1))
+	assert.False(t, cache.ownedL1)
+	assert.NoError(t, cache.Close())
+}
+
+func TestViaCep_TieredCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	cache, mock := newTestTieredCache(t, newFakeClock())
+
+	mock.ExpectSet("a", encodeGob(t, "alice"), 0).SetVal("OK")
+	mock.ExpectGet("b").SetVal(string(encodeGob(t, "bob")))
+	mock.ExpectGet("c").SetVal("invalid data")
+	mock.ExpectSet("d", encodeGob(t, "dave"), 0).SetErr(errors.New("error"))
+
+	assert.NoError(t, cache.Set(ctx, "a", "alice", 0))
+
+	var dest string
+	assert.True(t, cache.Get(ctx, "a", &dest))
+	assert.True(t, cache.Get(ctx, "b", &dest))
+	assert.False(t, cache.Get(ctx, "c", &dest))
+	assert.Error(t, cache.Set(ctx, "d", "dave", 0))
+
+	stats := cache.Stats()
+	assert.Equal(t, CacheStats{
+		Hits:           2,
+		Misses:         1,
+		Sets:           1,
+		SetErrors:      1,
+		Entries:        2,
+		Bytes:          stats.Bytes,
+		DecodeFailures: 1,
+	}, stats)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
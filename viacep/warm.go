@@ -0,0 +1,451 @@
+package viacep
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// warmChunkSize is the number of records Warm checks against and writes to the cache at once.
+const warmChunkSize = 100
+
+// errWarmNotDispatched marks the records left out of a warm-up because ctx was done before their
+// lookup started. They are not reported as failures.
+var errWarmNotDispatched = errors.New("warm lookup not dispatched")
+
+// WarmFormat selects how Warm reads its source.
+type WarmFormat int
+
+const (
+	// WarmJSONLines reads one JSON value per line: either a CEP as a JSON string, such as
+	// "01001-000", or an Address object. Blank lines are ignored.
+	WarmJSONLines WarmFormat = iota
+	// WarmCSV reads comma separated records. The first record is a header naming the columns
+	// after the JSON fields of Address, one of which must be "cep"; other columns are ignored.
+	// A source with a single column of CEPs may omit the header.
+	WarmCSV
+)
+
+// WarmOptions configures Warm.
+type WarmOptions struct {
+	// Format is the format of the source. Defaults to WarmJSONLines.
+	Format WarmFormat
+	// TTL is how long the warmed entries are kept in the cache and served without contacting
+	// the API, even past the cache TTL of the client. Defaults to the TTL of the lookups of the
+	// client, in which case warmed entries are refreshed like any other. Unknown CEPs are kept
+	// for the negative cache TTL instead.
+	TTL time.Duration
+	// Concurrency is the maximum number of CEPs fetched from the API at once. Defaults to 8.
+	Concurrency int
+	// RateLimiter paces the API requests of this warm-up, on top of any limiter set with
+	// WithRateLimiter.
+	RateLimiter RateLimiter
+	// OnProgress is called after each group of records is written to the cache, with the totals
+	// so far. Calls never overlap.
+	OnProgress func(report WarmReport)
+}
+
+// WarmReport summarises the work done by Warm.
+type WarmReport struct {
+	// Read is the number of records read from the source, including invalid ones.
+	Read int
+	// Skipped is the number of records whose CEP was already in the cache or appeared earlier in
+	// the source.
+	Skipped int
+	// Fetched is the number of CEPs looked up on the API.
+	Fetched int
+	// Stored is the number of entries written to the cache.
+	Stored int
+	// Failures lists the records that could not be warmed.
+	Failures []WarmFailure
+}
+
+// WarmFailure describes a record that Warm could not put in the cache.
+type WarmFailure struct {
+	// Line is the line of the record in the source, starting at 1.
+	Line int
+	// Input is the CEP of the record, or the raw record if it could not be parsed.
+	Input string
+	// Err is the reason of the failure, such as ErrInvalidCEP or ErrCepNotFound.
+	Err error
+}
+
+// warmRecord is a record of a Warm source. address is set when the record is a complete Address
+// that can be stored without asking the API.
+type warmRecord struct {
+	line    int
+	input   string
+	cep     CEP
+	address *Address
+	err     error
+}
+
+// Warm pre-populates the cache with the CEPs read from source, so that later lookups are served
+// without reaching the API, e.g. ahead of an expected traffic peak.
+//
+// Records holding a complete Address are stored as they are, while records holding only a CEP
+// are looked up on the API by at most opts.Concurrency workers, respecting the rate limiters.
+// CEPs already in the cache are skipped. Records are processed in groups of 100: each group is
+// checked with a single GetMulti call and written with a single SetMulti call when the cache is
+// a BatchCache.
+//
+// Invalid records and failed lookups are listed in the report and do not stop the warm-up. An
+// error is returned only if source cannot be read or ctx is done, together with the report of
+// the work done until then.
+//
+// Example:
+//
+//	file, _ := os.Open("top-ceps.jsonl")
+//	report, err := client.Warm(ctx, file, viacep.WarmOptions{TTL: 72 * time.Hour})
+func (v *ViaCep) Warm(ctx context.Context, source io.Reader, opts WarmOptions) (WarmReport, error) {
+	var report WarmReport
+	chunk := make([]warmRecord, 0, warmChunkSize)
+
+	for record, err := range readWarmSource(source, opts.Format) {
+		if err != nil {
+			return report, err
+		}
+
+		report.Read++
+		if record.err != nil {
+			report.fail(record, record.err)
+			continue
+		}
+
+		chunk = append(chunk, record)
+		if len(chunk) < warmChunkSize {
+			continue
+		}
+
+		if err := v.warmChunk(ctx, chunk, opts, &report); err != nil {
+			return report, err
+		}
+
+		chunk = chunk[:0]
+	}
+
+	return report, v.warmChunk(ctx, chunk, opts, &report)
+}
+
+// warmChunk warms the records of a group and reports progress.
+func (v *ViaCep) warmChunk(ctx context.Context, chunk []warmRecord, opts WarmOptions, report *WarmReport) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	pending := v.warmPending(ctx, chunk, report)
+
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = v.cepTTL()
+	}
+
+	found := make(map[string]any)
+	tombstones := make(map[string]any)
+	entries, errs := v.warmEntries(ctx, pending, opts, report)
+	for i, record := range pending {
+		key := v.cacheKey(record.cep.String())
+		switch {
+		case errors.Is(errs[i], errWarmNotDispatched):
+			continue
+		case errs[i] != nil:
+			report.fail(record, errs[i])
+		case entries[i].NotFound:
+			_, err := entries[i].result(record.cep)
+			report.fail(record, err)
+			if v.negativeCacheTTL > 0 {
+				tombstones[key] = entries[i]
+			}
+		default:
+			found[key] = entries[i]
+		}
+	}
+
+	v.warmStore(ctx, found, ttl, pending, report)
+	v.warmStore(ctx, tombstones, v.negativeCacheTTL, nil, report)
+
+	if opts.OnProgress != nil {
+		progress := *report
+		progress.Failures = slices.Clip(progress.Failures)
+		opts.OnProgress(progress)
+	}
+
+	return ctx.Err()
+}
+
+// warmPending returns the records of chunk whose CEP is neither in the cache at the current
+// schema version nor repeated, counting the others as skipped.
+func (v *ViaCep) warmPending(ctx context.Context, chunk []warmRecord, report *WarmReport) []warmRecord {
+	keys := make([]string, len(chunk))
+	for i, record := range chunk {
+		keys[i] = v.cacheKey(record.cep.String())
+	}
+
+	cached := getMulti(ctx, v.cache, keys, func() any { return new(cepEntry) })
+
+	pending := make([]warmRecord, 0, len(chunk))
+	seen := make(map[string]bool, len(chunk))
+	for i, record := range chunk {
+		entry, found := cached[keys[i]]
+		if seen[keys[i]] || found && entry.(*cepEntry).Version == CacheSchemaVersion {
+			report.Skipped++
+			continue
+		}
+
+		seen[keys[i]] = true
+		pending = append(pending, record)
+	}
+
+	return pending
+}
+
+// warmEntries returns the cache entries of records and the errors of their lookups, in order,
+// fetching the records without an address from the API. Addresses are fresh for opts.TTL. Once
+// ctx is done no more lookups are started, and the records left get errWarmNotDispatched.
+func (v *ViaCep) warmEntries(ctx context.Context, records []warmRecord, opts WarmOptions, report *WarmReport) ([]cepEntry, []error) {
+	entries := make([]cepEntry, len(records))
+	errs := make([]error, len(records))
+
+	freshFor := max(opts.TTL, 0)
+
+	var fetches []int
+	for i, record := range records {
+		if record.address != nil {
+			entries[i] = cepEntry{Version: CacheSchemaVersion, Address: *record.address, StoredAt: v.clock.Now(), FreshFor: freshFor}
+			continue
+		}
+
+		fetches = append(fetches, i)
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(fetches)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				entries[i], errs[i] = v.warmFetch(ctx, records[i].cep, opts.RateLimiter)
+				if !entries[i].NotFound {
+					entries[i].FreshFor = freshFor
+				}
+			}
+		}()
+	}
+
+	dispatched := 0
+dispatch:
+	for _, i := range fetches {
+		select {
+		case indexes <- i:
+			dispatched++
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+
+	close(indexes)
+	wg.Wait()
+	report.Fetched += dispatched
+
+	for _, i := range fetches[dispatched:] {
+		errs[i] = errWarmNotDispatched
+	}
+
+	return entries, errs
+}
+
+// warmFetch looks cep up on the API, waiting for limiter first.
+func (v *ViaCep) warmFetch(ctx context.Context, cep CEP, limiter RateLimiter) (cepEntry, error) {
+	if limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return cepEntry{}, err
+		}
+	}
+
+	return v.fetchCep(ctx, cep)
+}
+
+// warmStore writes entries to the cache. If the write fails, the records of entries among
+// records are reported as failed.
+func (v *ViaCep) warmStore(ctx context.Context, entries map[string]any, ttl time.Duration, records []warmRecord, report *WarmReport) {
+	if len(entries) == 0 {
+		return
+	}
+
+	if err := setMulti(ctx, v.cache, entries, ttl); err != nil {
+		v.stats.setErrors.Add(uint64(len(entries)))
+		for _, record := range records {
+			if _, ok := entries[v.cacheKey(record.cep.String())]; ok {
+				report.fail(record, err)
+			}
+		}
+
+		return
+	}
+
+	v.stats.sets.Add(uint64(len(entries)))
+	report.Stored += len(entries)
+}
+
+func (r *WarmReport) fail(record warmRecord, err error) {
+	r.Failures = append(r.Failures, WarmFailure{Line: record.line, Input: record.input, Err: err})
+}
+
+// readWarmSource yields the records of source. Records that cannot be parsed carry their error,
+// while errors reading source end the sequence.
+func readWarmSource(source io.Reader, format WarmFormat) iter.Seq2[warmRecord, error] {
+	switch format {
+	case WarmJSONLines:
+		return readWarmJSONLines(source)
+	case WarmCSV:
+		return readWarmCSV(source)
+	default:
+		return func(yield func(warmRecord, error) bool) {
+			yield(warmRecord{}, fmt.Errorf("unsupported warm format %d", format))
+		}
+	}
+}
+
+func readWarmJSONLines(source io.Reader) iter.Seq2[warmRecord, error] {
+	return func(yield func(warmRecord, error) bool) {
+		scanner := bufio.NewScanner(source)
+		for line := 1; scanner.Scan(); line++ {
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+
+			if !yield(parseWarmJSON(line, data), nil) {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			yield(warmRecord{}, fmt.Errorf("failed to read warm source: %w", err))
+		}
+	}
+}
+
+// parseWarmJSON parses a JSON Lines record, which is either a CEP string or an Address object.
+func parseWarmJSON(line int, data []byte) warmRecord {
+	var address Address
+	if data[0] == '"' {
+		if err := json.Unmarshal(data, &address.Cep); err != nil {
+			return warmRecord{line: line, input: string(data), err: fmt.Errorf("failed to decode record: %w", err)}
+		}
+	} else if err := json.Unmarshal(data, &address); err != nil {
+		return warmRecord{line: line, input: string(data), err: fmt.Errorf("failed to decode record: %w", err)}
+	}
+
+	return newWarmRecord(line, address)
+}
+
+func readWarmCSV(source io.Reader) iter.Seq2[warmRecord, error] {
+	return func(yield func(warmRecord, error) bool) {
+		reader := csv.NewReader(source)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+
+		header, first, err := readWarmCSVHeader(reader)
+		if errors.Is(err, io.EOF) {
+			return
+		}
+
+		if err != nil {
+			yield(warmRecord{}, err)
+			return
+		}
+
+		for fields := first; ; fields = nil {
+			if fields == nil {
+				fields, err = reader.Read()
+			}
+
+			if errors.Is(err, io.EOF) {
+				return
+			}
+
+			if err != nil {
+				yield(warmRecord{}, fmt.Errorf("failed to read warm source: %w", err))
+				return
+			}
+
+			line, _ := reader.FieldPos(0)
+			if !yield(parseWarmCSV(line, fields, header), nil) {
+				return
+			}
+		}
+	}
+}
+
+// readWarmCSVHeader reads the first record of a CSV source and returns the names of the columns.
+// A first record holding a single CEP starts a headerless source and is returned as first. An
+// empty source returns io.EOF.
+func readWarmCSVHeader(reader *csv.Reader) (header, first []string, err error) {
+	record, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, io.EOF
+		}
+
+		return nil, nil, fmt.Errorf("failed to read warm source: %w", err)
+	}
+
+	if len(record) == 1 {
+		if _, err := ParseCEP(record[0]); err == nil {
+			return []string{"cep"}, record, nil
+		}
+	}
+
+	for i, column := range record {
+		record[i] = strings.ToLower(strings.TrimSpace(column))
+	}
+
+	if !slices.Contains(record, "cep") {
+		return nil, nil, errors.New("failed to read warm source: missing cep column in header")
+	}
+
+	return record, nil, nil
+}
+
+// parseWarmCSV parses a CSV record whose fields are named by columns.
+func parseWarmCSV(line int, fields, columns []string) warmRecord {
+	var address Address
+	addressFields := addressFieldsByKey(&address)
+	for i, value := range fields {
+		if i < len(columns) && addressFields[columns[i]] != nil {
+			*addressFields[columns[i]] = strings.TrimSpace(value)
+		}
+	}
+
+	return newWarmRecord(line, address)
+}
+
+// newWarmRecord validates the CEP of address. An address holding nothing but its CEP is a
+// record to look up.
+func newWarmRecord(line int, address Address) warmRecord {
+	record := warmRecord{line: line, input: address.Cep}
+
+	record.cep, record.err = ParseCEP(address.Cep)
+	if record.err == nil && address != (Address{Cep: address.Cep}) {
+		record.address = &address
+	}
+
+	return record
+}
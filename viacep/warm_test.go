@@ -0,0 +1,254 @@
+package viacep
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// cancelingLimiter is a RateLimiter that cancels the context of the caller on its first permit.
+type cancelingLimiter struct {
+	cancel context.CancelFunc
+}
+
+func (l cancelingLimiter) Wait(ctx context.Context) error {
+	l.cancel()
+	return ctx.Err()
+}
+
+func TestViaCep_Warm(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("json lines", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		source := strings.Join([]string{
+			`"01001000"`,
+			`{"cep": "01310-100", "logradouro": "Avenida Paulista", "uf": "SP"}`,
+			``,
+			`{"cep": "20040-020"}`,
+			`"01001-000"`,
+			`"99999999"`,
+			`"123"`,
+			`not json`,
+		}, "\n")
+
+		report, err := c.Warm(ctx, strings.NewReader(source), WarmOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, 7, report.Read)
+		assert.Equal(t, 1, report.Skipped)
+		assert.Equal(t, 3, report.Fetched)
+		assert.Equal(t, 4, report.Stored)
+		assert.ElementsMatch(t, []string{"/ws/01001000/json/", "/ws/20040020/json/", "/ws/99999999/json/"}, srv.requests())
+
+		assert.Len(t, report.Failures, 3)
+		assert.Equal(t, WarmFailure{Line: 6, Input: "99999999", Err: report.Failures[2].Err}, report.Failures[2])
+		assert.ErrorIs(t, report.Failures[2].Err, ErrCepNotFound)
+		assert.Equal(t, 7, report.Failures[0].Line)
+		assert.ErrorIs(t, report.Failures[0].Err, ErrInvalidCEP)
+		assert.Equal(t, WarmFailure{Line: 8, Input: "not json", Err: report.Failures[1].Err}, report.Failures[1])
+		assert.ErrorContains(t, report.Failures[1].Err, "failed to decode record")
+
+		address, info, err := c.CepWithInfo(ctx, "01310100")
+		assert.NoError(t, err)
+		assert.Equal(t, &Address{Cep: "01310-100", Logradouro: "Avenida Paulista", Uf: "SP"}, address)
+		assert.True(t, info.Cached)
+
+		_, err = c.Cep(ctx, "99999999")
+		assert.ErrorIs(t, err, ErrCepNotFound)
+		assert.Len(t, srv.requests(), 3)
+	})
+
+	t.Run("csv with header", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		source := "Logradouro,CEP,ignored\nPraça da Sé,01001-000,x\n,20040020,\n,invalid,\n"
+
+		report, err := c.Warm(ctx, strings.NewReader(source), WarmOptions{Format: WarmCSV})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, report.Read)
+		assert.Equal(t, 2, report.Stored)
+		assert.Equal(t, []string{"/ws/20040020/json/"}, srv.requests())
+		assert.Len(t, report.Failures, 1)
+		assert.Equal(t, 4, report.Failures[0].Line)
+		assert.ErrorIs(t, report.Failures[0].Err, ErrInvalidCEP)
+
+		address, err := c.Cep(ctx, "01001000")
+		assert.NoError(t, err)
+		assert.Equal(t, &Address{Cep: "01001-000", Logradouro: "Praça da Sé"}, address)
+	})
+
+	t.Run("csv without header", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		report, err := c.Warm(ctx, strings.NewReader("01001000\n20040-020\n"), WarmOptions{Format: WarmCSV})
+		assert.NoError(t, err)
+		assert.Equal(t, WarmReport{Read: 2, Fetched: 2, Stored: 2}, report)
+	})
+
+	t.Run("invalid csv", func(t *testing.T) {
+		c := New(WithNoCache())
+
+		_, err := c.Warm(ctx, strings.NewReader("logradouro\nPraça da Sé\n"), WarmOptions{Format: WarmCSV})
+		assert.EqualError(t, err, "failed to read warm source: missing cep column in header")
+
+		report, err := c.Warm(ctx, strings.NewReader("cep\n\"01001000\n"), WarmOptions{Format: WarmCSV})
+		assert.ErrorContains(t, err, "failed to read warm source")
+		assert.Zero(t, report.Read)
+
+		report, err = c.Warm(ctx, strings.NewReader(""), WarmOptions{Format: WarmCSV})
+		assert.NoError(t, err)
+		assert.Equal(t, WarmReport{}, report)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		c := New(WithNoCache())
+
+		_, err := c.Warm(ctx, strings.NewReader(`"01001000"`), WarmOptions{Format: WarmFormat(9)})
+		assert.EqualError(t, err, "unsupported warm format 9")
+	})
+
+	t.Run("skips entries already cached", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		legacy := cepEntry{Address: Address{Cep: "20040-020"}}
+		assert.NoError(t, c.cache.Set(ctx, cacheKey("20040020"), legacy, 0))
+		_, err := c.Cep(ctx, "01001000")
+		assert.NoError(t, err)
+
+		report, err := c.Warm(ctx, strings.NewReader("\"01001000\"\n\"20040020\"\n"), WarmOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, WarmReport{Read: 2, Skipped: 1, Fetched: 1, Stored: 1}, report)
+		assert.Equal(t, []string{"/ws/01001000/json/", "/ws/20040020/json/"}, srv.requests())
+	})
+
+	t.Run("single round trips with the chosen TTL", func(t *testing.T) {
+		srv := newBatchServer(t)
+		client, mock := redismock.NewClientMock()
+		clock := newFakeClock()
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithCache(NewRedisCache(client)), WithClock(clock))
+
+		ttl := 72 * time.Hour
+		mock.ExpectMGet(cacheKey("01001000"), cacheKey("20040020")).SetVal([]any{nil, nil})
+		entry := cepEntry{Version: CacheSchemaVersion, Address: Address{Cep: "01001000"}, StoredAt: clock.Now(), FreshFor: ttl}
+		mock.ExpectSet(cacheKey("01001000"), encodeGob(t, entry), ttl).SetVal("OK")
+		entry = cepEntry{Version: CacheSchemaVersion, Address: Address{Cep: "20040020"}, StoredAt: clock.Now(), FreshFor: ttl}
+		mock.ExpectSet(cacheKey("20040020"), encodeGob(t, entry), ttl).SetVal("OK")
+		mock.MatchExpectationsInOrder(false)
+
+		source := "{\"cep\": \"01001-000\", \"bairro\": \"\"}\n\"20040020\"\n"
+		report, err := c.Warm(ctx, strings.NewReader(source), WarmOptions{TTL: ttl})
+		assert.NoError(t, err)
+		assert.Equal(t, WarmReport{Read: 2, Fetched: 2, Stored: 2}, report)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("fresh for the chosen TTL", func(t *testing.T) {
+		srv := newBatchServer(t)
+		clock := newFakeClock()
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithClock(clock), WithCacheTTL(time.Hour))
+		defer c.Close()
+
+		source := "\"01001000\"\n{\"cep\": \"01310-100\", \"uf\": \"SP\"}\n"
+		_, err := c.Warm(ctx, strings.NewReader(source), WarmOptions{TTL: 72 * time.Hour})
+		assert.NoError(t, err)
+
+		clock.Advance(2 * time.Hour)
+		for _, cep := range []string{"01001000", "01310100"} {
+			_, info, err := c.CepWithInfo(ctx, cep)
+			assert.NoError(t, err)
+			assert.True(t, info.Cached)
+		}
+		assert.Len(t, srv.requests(), 1)
+
+		clock.Advance(71 * time.Hour)
+		_, info, err := c.CepWithInfo(ctx, "01310100")
+		assert.NoError(t, err)
+		assert.False(t, info.Cached)
+		assert.Len(t, srv.requests(), 2)
+	})
+
+	t.Run("rate limiter and progress", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		source := `"` + strings.Join(sequentialCeps(50), "\"\n\"") + "\"\n"
+		source += strings.Repeat("\"01001000\"\n", 100)
+
+		limiter := &countingLimiter{}
+		var progress []WarmReport
+		report, err := c.Warm(ctx, strings.NewReader(source), WarmOptions{
+			Concurrency: 4,
+			RateLimiter: limiter,
+			OnProgress:  func(report WarmReport) { progress = append(progress, report) },
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, WarmReport{Read: 150, Skipped: 100, Fetched: 50, Stored: 50}, report)
+		assert.Equal(t, []WarmReport{{Read: 100, Skipped: 50, Fetched: 50, Stored: 50}, report}, progress)
+		assert.Equal(t, int32(50), limiter.permits.Load())
+		assert.LessOrEqual(t, srv.maxInFlight.Load(), int32(4))
+	})
+
+	t.Run("set errors", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL), WithCache(failingCache{noopCache{}}))
+
+		report, err := c.Warm(ctx, strings.NewReader(`"01001000"`), WarmOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, WarmReport{Read: 1, Fetched: 1, Failures: report.Failures}, report)
+		assert.Len(t, report.Failures, 1)
+		assert.Equal(t, "01001000", report.Failures[0].Input)
+		assert.EqualError(t, report.Failures[0].Err, "error")
+		assert.Equal(t, CacheStats{SetErrors: 1}, c.Stats())
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		report, err := c.Warm(canceled, strings.NewReader(`"01001000"`), WarmOptions{})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, WarmReport{Read: 1}, report)
+		assert.Empty(t, srv.requests())
+	})
+
+	t.Run("context canceled during lookups", func(t *testing.T) {
+		srv := newBatchServer(t)
+		c := New(WithHTTP(NewHTTPClient(0)), WithBaseURL(srv.URL))
+		defer c.Close()
+
+		canceled, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		source := `"` + strings.Join(sequentialCeps(50), "\"\n\"") + "\"\n"
+		report, err := c.Warm(canceled, strings.NewReader(source), WarmOptions{
+			Concurrency: 1,
+			RateLimiter: cancelingLimiter{cancel: cancel},
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 50, report.Read)
+		assert.Less(t, report.Fetched, 50)
+		assert.Len(t, report.Failures, report.Fetched)
+		for _, failure := range report.Failures {
+			assert.ErrorIs(t, failure.Err, context.Canceled)
+		}
+		assert.Empty(t, srv.requests())
+	})
+}